@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vib795/epub2pdf/internal/converter"
+	"github.com/vib795/epub2pdf/internal/epub"
+)
+
+var (
+	batchOutput string
+	batchJobs   int
+	batchForce  bool
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <dir|glob>...",
+	Short: "Convert many EPUB files with one browser",
+	Long: `Convert every EPUB file in the given directories (searched recursively)
+and glob patterns, rendering several books at once in tabs of a single
+headless Chrome.
+
+Outputs newer than their input are skipped. With --output-dir the
+directory tree of the inputs is mirrored there; otherwise each PDF is
+written next to its EPUB. All conversion flags of the root command apply
+to every book.
+
+Examples:
+  epub2pdf batch library/                        # library/**/*.epub
+  epub2pdf batch library/ -o pdf/ -j 8           # Mirror into pdf/, 8 tabs
+  epub2pdf batch "books/*.epub" --page-size 6x9  # Glob (quoted for the shell)
+  epub2pdf batch library/ --force                # Convert everything again`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runBatch,
+}
+
+func init() {
+	batchCmd.Flags().StringVarP(&batchOutput, "output-dir", "o", "", "Root directory for the PDFs, mirroring the input tree (default: next to each EPUB)")
+	batchCmd.Flags().IntVarP(&batchJobs, "jobs", "j", min(4, runtime.NumCPU()), "Number of books converted at once")
+	batchCmd.Flags().BoolVar(&batchForce, "force", false, "Convert books whose PDF is already up to date")
+	addConversionFlags(batchCmd)
+	rootCmd.AddCommand(batchCmd)
+}
+
+// batchJob is one book to convert. rel is its path below the directory or
+// glob it was found through, mirrored under the output directory.
+type batchJob struct {
+	input  string
+	rel    string
+	output string
+}
+
+// batchResult is the outcome of a batch job
+type batchResult struct {
+	status   string // converted, skipped, failed or canceled
+	err      error
+	warnings []string
+	duration time.Duration
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	if batchJobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+	opts, err := conversionOptions(cmd)
+	if err != nil {
+		return err
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	// Progress lines replace the per-book messages, which would interleave
+	opts.Progress = nil
+
+	jobs, err := collectBatchJobs(args)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no EPUB files found")
+	}
+
+	results := make([]batchResult, len(jobs))
+	var pending []int
+	for i, job := range jobs {
+		if !batchForce && upToDate(job.input, job.output) {
+			results[i] = batchResult{status: "skipped"}
+			if verbose {
+				fmt.Printf("⏭️  %s (up to date)\n", job.input)
+			}
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	start := time.Now()
+	if len(pending) > 0 {
+		fmt.Printf("🔄 Converting %d of %d books, %d at a time...\n", len(pending), len(jobs), min(batchJobs, len(pending)))
+		ctx := cmd.Context()
+		browser, err := converter.NewBrowser(ctx, opts.Chrome)
+		if err != nil {
+			return err
+		}
+		defer browser.Close()
+
+		work := make(chan int)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		done := 0
+		for w := 0; w < min(batchJobs, len(pending)); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range work {
+					if ctx.Err() != nil {
+						results[i] = batchResult{status: "canceled"}
+						continue
+					}
+					result := convertBatchJob(ctx, browser, jobs[i], opts)
+					results[i] = result
+					if result.err != nil && ctx.Err() != nil {
+						// Interrupted, not failed
+						results[i].status = "canceled"
+						continue
+					}
+
+					mu.Lock()
+					done++
+					if result.err != nil {
+						fmt.Printf("[%d/%d] ❌ %s: %v\n", done, len(pending), jobs[i].input, result.err)
+					} else {
+						fmt.Printf("[%d/%d] ✅ %s (%s)\n", done, len(pending), jobs[i].output, result.duration.Round(100*time.Millisecond))
+					}
+					for _, warning := range result.warnings {
+						fmt.Printf("[%d/%d] ⚠️  %s: %s\n", done, len(pending), jobs[i].input, warning)
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, i := range pending {
+			work <- i
+		}
+		close(work)
+		wg.Wait()
+	}
+
+	if err := printBatchSummary(jobs, results, time.Since(start)); err != nil {
+		return err
+	}
+	return cmd.Context().Err()
+}
+
+// convertBatchJob converts one book in a tab of the shared browser
+func convertBatchJob(ctx context.Context, browser *converter.Browser, job batchJob, opts converter.Options) batchResult {
+	start := time.Now()
+	result := func(err error, warnings ...string) batchResult {
+		if err != nil {
+			return batchResult{status: "failed", err: err, warnings: warnings, duration: time.Since(start)}
+		}
+		return batchResult{status: "converted", warnings: warnings, duration: time.Since(start)}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(job.output), 0755); err != nil {
+		return result(fmt.Errorf("failed to create output directory: %w", err))
+	}
+	book, err := epub.Parse(job.input)
+	if err != nil {
+		return result(fmt.Errorf("failed to parse EPUB: %w", err))
+	}
+	defer book.Close()
+
+	converted, err := browser.Convert(ctx, book, job.output, opts)
+	return result(err, converted.Warnings...)
+}
+
+// collectBatchJobs finds the EPUB files of directories and glob patterns.
+// Two books whose PDFs would have the same path, such as lib1/x.epub and
+// lib2/x.epub mirrored into one output directory, are an error.
+func collectBatchJobs(args []string) ([]batchJob, error) {
+	var jobs []batchJob
+	seen := make(map[string]bool)
+	add := func(path, base string) {
+		abs, err := filepath.Abs(path)
+		if err != nil || seen[abs] {
+			return
+		}
+		seen[abs] = true
+		rel, err := filepath.Rel(base, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			rel = filepath.Base(path)
+		}
+		jobs = append(jobs, batchJob{input: path, rel: rel, output: batchOutputPath(path, rel)})
+	}
+	walk := func(dir, base string) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isEPUB(path) {
+				add(path, base)
+			}
+			return nil
+		})
+	}
+
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			if err := walk(arg, arg); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", arg, err)
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+		base := globBase(arg)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				if err := walk(match, base); err != nil {
+					return nil, fmt.Errorf("failed to read %s: %w", match, err)
+				}
+			} else if isEPUB(match) {
+				add(match, base)
+			}
+		}
+	}
+
+	outputs := make(map[string]string) // Absolute output path -> input
+	for _, job := range jobs {
+		out, err := filepath.Abs(job.output)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := outputs[out]; ok {
+			return nil, fmt.Errorf("%s and %s would both be converted to %s: convert them in separate runs", other, job.input, job.output)
+		}
+		outputs[out] = job.input
+	}
+	return jobs, nil
+}
+
+// globBase returns the directory part of a glob pattern before its first
+// wildcard, which the matches' paths are mirrored relative to
+func globBase(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// batchOutputPath returns the PDF path of an input: next to it, or at its
+// relative path under the output directory
+func batchOutputPath(input, rel string) string {
+	if batchOutput == "" {
+		return strings.TrimSuffix(input, filepath.Ext(input)) + ".pdf"
+	}
+	return filepath.Join(batchOutput, strings.TrimSuffix(rel, filepath.Ext(rel))+".pdf")
+}
+
+func isEPUB(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".epub")
+}
+
+// upToDate reports whether output exists and is newer than input
+func upToDate(input, output string) bool {
+	in, err := os.Stat(input)
+	if err != nil {
+		return false
+	}
+	out, err := os.Stat(output)
+	return err == nil && !out.ModTime().Before(in.ModTime())
+}
+
+// printBatchSummary prints the counts and the failures, and returns an
+// error when any book failed
+func printBatchSummary(jobs []batchJob, results []batchResult, elapsed time.Duration) error {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.status]++
+	}
+
+	fmt.Println()
+	fmt.Println("╔════════════════════════════════════════════════════════════╗")
+	fmt.Println("║                       Batch Summary                         ║")
+	fmt.Println("╠════════════════════════════════════════════════════════════╣")
+	fmt.Printf("║ Converted: %-48d ║\n", counts["converted"])
+	fmt.Printf("║ Skipped:   %-48s ║\n", fmt.Sprintf("%d (up to date)", counts["skipped"]))
+	fmt.Printf("║ Failed:    %-48d ║\n", counts["failed"])
+	if counts["canceled"] > 0 {
+		fmt.Printf("║ Canceled:  %-48d ║\n", counts["canceled"])
+	}
+	fmt.Printf("║ Time:      %-48s ║\n", elapsed.Round(time.Second))
+
+	if counts["failed"] > 0 {
+		fmt.Println("╠════════════════════════════════════════════════════════════╣")
+		fmt.Println("║                          Failures                           ║")
+		fmt.Println("╠════════════════════════════════════════════════════════════╣")
+		for i, r := range results {
+			if r.status != "failed" {
+				continue
+			}
+			fmt.Printf("║ %-59s ║\n", truncate(jobs[i].input, 59))
+			fmt.Printf("║   %-57s ║\n", truncate(r.err.Error(), 57))
+		}
+	}
+	fmt.Println("╚════════════════════════════════════════════════════════════╝")
+
+	if counts["failed"] > 0 {
+		return fmt.Errorf("%d of %d books failed to convert", counts["failed"], len(jobs))
+	}
+	return nil
+}
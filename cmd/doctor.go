@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vib795/epub2pdf/internal/doctor"
+)
+
+var doctorTimeout time.Duration
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that this machine can convert books",
+	Long: `Diagnose the environment conversions run in: find Chrome and report
+its version, print a test page, check the temp directory and Chrome's
+sandbox, and list the fonts installed for Latin, CJK, Arabic and
+Devanagari text. Problems come with suggested fixes.
+
+Pass the same --chrome-path, --chrome-flag or --remote-chrome as for your
+conversions.
+
+Examples:
+  epub2pdf doctor
+  epub2pdf doctor --chrome-path /opt/chromium/chrome
+  epub2pdf doctor --remote-chrome ws://localhost:9222`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+	// Failed checks aren't usage errors
+	SilenceUsage: true,
+}
+
+func init() {
+	doctorCmd.Flags().DurationVar(&doctorTimeout, "timeout", 30*time.Second, "Time limit for starting Chrome and for the test render")
+	addChromeFlags(doctorCmd)
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if doctorTimeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+	ctx := cmd.Context()
+	chrome := chromeOptions()
+
+	// Print each check as it finishes, so a hang shows where it is
+	var checks []doctor.Check
+	report := func(check doctor.Check) {
+		checks = append(checks, check)
+		icon := "✅"
+		switch check.Status {
+		case doctor.Warning:
+			icon = "⚠️ "
+		case doctor.Failed:
+			icon = "❌"
+		}
+		fmt.Printf("%s %-17s %s\n", icon, check.Name+":", check.Detail)
+	}
+
+	fmt.Println("🩺 Checking this machine...")
+	path, check := doctor.FindChrome(chrome)
+	report(check)
+	if path != "" {
+		if chrome.Remote == "" {
+			report(doctor.Sandbox(chrome, path))
+		}
+		browser, check := doctor.StartChrome(ctx, chrome, doctorTimeout)
+		report(check)
+		if browser != nil {
+			defer browser.Close()
+			report(doctor.Render(ctx, browser, doctorTimeout))
+		}
+	}
+	report(doctor.TempDir())
+	if chrome.Remote == "" {
+		// A remote Chrome uses the fonts of its own machine
+		for _, check := range doctor.Fonts(ctx) {
+			report(check)
+		}
+	}
+
+	failed := 0
+	fmt.Println()
+	for _, check := range checks {
+		if check.Status == doctor.Failed {
+			failed++
+		}
+		if check.Fix != "" {
+			fmt.Printf("🔧 %s: %s\n", check.Name, check.Fix)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	fmt.Println("🎉 Ready to convert")
+	return nil
+}
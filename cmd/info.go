@@ -14,7 +14,8 @@ var infoCmd = &cobra.Command{
 	Short: "Display EPUB metadata and structure",
 	Long: `Display information about an EPUB file without converting it.
 
-Shows the book title, author, number of chapters, and chapter list.
+Shows the book title, author, number of chapters, chapter list and
+table of contents.
 
 Example:
   epub2pdf info book.epub`,
@@ -43,6 +44,7 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse EPUB: %w", err)
 	}
+	defer book.Close()
 
 	// Display info
 	fmt.Println("╔════════════════════════════════════════════════════════════╗")
@@ -51,7 +53,26 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	fmt.Printf("║ File:     %-49s ║\n", truncate(inputPath, 49))
 	fmt.Printf("║ Title:    %-49s ║\n", truncate(book.Title, 49))
 	fmt.Printf("║ Author:   %-49s ║\n", truncate(book.Author, 49))
+	if book.Metadata.Publisher != "" {
+		fmt.Printf("║ Publisher: %-48s ║\n", truncate(book.Metadata.Publisher, 48))
+	}
+	if book.Metadata.Date != "" {
+		fmt.Printf("║ Date:     %-49s ║\n", truncate(book.Metadata.Date, 49))
+	}
+	if len(book.Metadata.Languages) > 0 {
+		fmt.Printf("║ Language: %-49s ║\n", truncate(strings.Join(book.Metadata.Languages, ", "), 49))
+	}
+	if isbn := book.Metadata.ISBN(); isbn != "" {
+		fmt.Printf("║ ISBN:     %-49s ║\n", truncate(isbn, 49))
+	}
+	if len(book.Metadata.Subjects) > 0 {
+		fmt.Printf("║ Subjects: %-49s ║\n", truncate(strings.Join(book.Metadata.Subjects, ", "), 49))
+	}
+	if book.FixedLayout {
+		fmt.Printf("║ Layout:   %-49s ║\n", "Fixed (pre-paginated)")
+	}
 	fmt.Printf("║ Chapters: %-49d ║\n", len(book.Chapters))
+	fmt.Printf("║ TOC:      %-49d ║\n", countTOCEntries(book.TOC))
 	fmt.Printf("║ CSS:      %-49d ║\n", len(book.CSS))
 	fmt.Println("╠════════════════════════════════════════════════════════════╣")
 	fmt.Println("║                      Chapter List                           ║")
@@ -66,11 +87,36 @@ func runInfo(cmd *cobra.Command, args []string) error {
 		fmt.Printf("║ %3d. %-54s ║\n", i+1, truncate(chapter.Title, 54))
 	}
 
+	if len(book.TOC) > 0 {
+		fmt.Println("╠════════════════════════════════════════════════════════════╣")
+		fmt.Println("║                    Table of Contents                        ║")
+		fmt.Println("╠════════════════════════════════════════════════════════════╣")
+
+		shown, total := 0, countTOCEntries(book.TOC)
+		epub.WalkTOC(book.TOC, func(entry epub.TOCEntry, depth int) {
+			if shown == 40 {
+				fmt.Printf("║   ... and %-3d more entries                                 ║\n", total-shown)
+			}
+			shown++
+			if shown > 40 {
+				return
+			}
+			indent := strings.Repeat("  ", min(depth, 8))
+			fmt.Printf("║ %-59s ║\n", truncate(indent+entry.Label, 59))
+		})
+	}
+
 	fmt.Println("╚════════════════════════════════════════════════════════════╝")
 
 	return nil
 }
 
+func countTOCEntries(toc []epub.TOCEntry) int {
+	count := 0
+	epub.WalkTOC(toc, func(epub.TOCEntry, int) { count++ })
+	return count
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s + strings.Repeat(" ", maxLen-len(s))
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vib795/epub2pdf/internal/converter"
+	"github.com/vib795/epub2pdf/internal/epub"
+)
+
+// Conversion flags, shared by the root and batch commands
+var (
+	pageSize   string
+	margin     string
+	marginTop  string
+	marginBot  string
+	marginIn   string
+	marginOut  string
+	landscape  bool
+	noBG       bool
+	scale      float64
+	bookmarks  bool
+	noBookmark bool
+	titlePage  string
+	fixedMode  string
+	headers    string
+	header     string
+	footer     string
+	tocPage    bool
+	cssPage    bool
+	theme      string
+	fontFamily string
+	fontSize   string
+	lineHeight float64
+	userCSS    []string
+	noBookCSS  bool
+	printMode  bool
+	bleed      string
+	cropMarks  bool
+	timeout    time.Duration
+	verbose    bool
+)
+
+// Browser flags, shared by every command that starts Chrome
+var (
+	chromePath   string
+	chromeFlags  []string
+	remoteChrome string
+)
+
+// addConversionFlags registers the flags that control how a book is rendered
+func addConversionFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVarP(&pageSize, "page-size", "p", "A4", "Page size: "+strings.Join(converter.PageSizeNames(), ", ")+", or WxH with in, mm, cm or pt")
+	flags.StringVarP(&margin, "margin", "m", "0.5in", "Page margins: one to four lengths in CSS order (in, mm, cm or pt; bare numbers are inches)")
+	flags.StringVar(&marginTop, "margin-top", "", "Top margin (default: --margin)")
+	flags.StringVar(&marginBot, "margin-bottom", "", "Bottom margin (default: --margin)")
+	flags.StringVar(&marginIn, "margin-inside", "", "Inside (binding) margin (default: --margin)")
+	flags.StringVar(&marginOut, "margin-outside", "", "Outside margin (default: --margin)")
+	flags.BoolVarP(&landscape, "landscape", "l", false, "Use landscape orientation")
+	flags.BoolVar(&noBG, "no-background", false, "Don't print background graphics")
+	flags.Float64VarP(&scale, "scale", "s", 1.0, "Scale factor (0.1 - 2.0)")
+	flags.BoolVar(&bookmarks, "bookmarks", true, "Generate PDF bookmarks from the book's table of contents")
+	flags.BoolVar(&noBookmark, "no-bookmarks", false, "Don't generate PDF bookmarks")
+	flags.StringVar(&titlePage, "title-page", epub.TitlePageCover, "First page: cover, generated or none")
+	flags.StringVar(&fixedMode, "fixed-layout", epub.FixedLayoutFit, "Fixed-layout pages: fit (scale to the page size) or viewport (use each page's own size)")
+	flags.StringVar(&headers, "headers", "none", "Header/footer preset: "+strings.Join(epub.PresetNames(), ", "))
+	flags.StringVar(&header, "header", "", "Header template \"left|center|right\" with {page}, {pages}, {title}, {author}, {chapter}")
+	flags.StringVar(&footer, "footer", "", "Footer template, same syntax as --header")
+	flags.BoolVar(&tocPage, "toc-page", false, "Print a table of contents with page numbers after the title page")
+	flags.BoolVar(&cssPage, "prefer-css-page-size", false, "Let the book's @page rules set the page size and margins (flags fill in the rest)")
+	flags.StringVar(&theme, "theme", "", "Typography theme: "+strings.Join(epub.ThemeNames(), ", "))
+	flags.StringVar(&fontFamily, "font-family", "", "Body font family, e.g. \"Helvetica, sans-serif\" (overrides the theme)")
+	flags.StringVar(&fontSize, "font-size", "", "Body font size, e.g. 12pt (overrides the theme)")
+	flags.Float64Var(&lineHeight, "line-height", 0, "Line height as a multiple of the font size, e.g. 1.4 (overrides the theme)")
+	flags.StringArrayVar(&userCSS, "css", nil, "Stylesheet applied after the book's (repeatable)")
+	flags.BoolVar(&noBookCSS, "no-book-css", false, "Drop the book's own stylesheets")
+	flags.BoolVar(&printMode, "print", false, "Print-ready output: mirrored margins and chapters starting on right-hand pages")
+	flags.StringVar(&bleed, "bleed", "", "Bleed around the trimmed page in print mode (e.g. 0.125in or 3mm)")
+	flags.BoolVar(&cropMarks, "crop-marks", false, "Draw crop marks outside the bleed in print mode")
+	flags.DurationVar(&timeout, "timeout", converter.DefaultTimeout, "Time limit for converting one book, e.g. 10m; 0 for none")
+	flags.BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
+	cmd.MarkFlagsMutuallyExclusive("bookmarks", "no-bookmarks")
+	addChromeFlags(cmd)
+}
+
+// addChromeFlags registers the flags that choose and launch Chrome
+func addChromeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&chromePath, "chrome-path", "", "Chrome or Chromium executable (default: $"+converter.ChromeEnv+", then the usual locations)")
+	cmd.Flags().StringArrayVar(&chromeFlags, "chrome-flag", nil, "Extra Chrome flag, e.g. no-sandbox or window-size=800,600 (repeatable)")
+	cmd.Flags().StringVar(&remoteChrome, "remote-chrome", "", "DevTools URL of a running Chrome to use instead, e.g. ws://localhost:9222")
+	cmd.MarkFlagsMutuallyExclusive("remote-chrome", "chrome-path")
+	cmd.MarkFlagsMutuallyExclusive("remote-chrome", "chrome-flag")
+}
+
+// chromeOptions returns the browser flags
+func chromeOptions() converter.ChromeOptions {
+	return converter.ChromeOptions{Path: chromePath, Flags: chromeFlags, Remote: remoteChrome}
+}
+
+// conversionOptions builds the conversion options from the flags of cmd.
+// Validate them once the command has set its own.
+func conversionOptions(cmd *cobra.Command) (converter.Options, error) {
+	margins, err := parseMargins()
+	if err != nil {
+		return converter.Options{}, err
+	}
+
+	// Resolve headers and footers: explicit templates override the preset
+	preset, ok := epub.HeaderFooterPresets[headers]
+	if !ok {
+		return converter.Options{}, fmt.Errorf("invalid headers preset: %s (valid: %s)", headers, strings.Join(epub.PresetNames(), ", "))
+	}
+	if cmd.Flags().Changed("header") {
+		preset.Header = header
+	}
+	if cmd.Flags().Changed("footer") {
+		preset.Footer = footer
+	}
+
+	// Read the user stylesheets
+	var stylesheets []string
+	for _, path := range userCSS {
+		css, err := os.ReadFile(path)
+		if err != nil {
+			return converter.Options{}, fmt.Errorf("--css: %w", err)
+		}
+		stylesheets = append(stylesheets, string(css))
+	}
+
+	var bleedSize float64
+	if bleed != "" {
+		if bleedSize, err = converter.ParseLength(bleed); err != nil {
+			return converter.Options{}, fmt.Errorf("--bleed: %w", err)
+		}
+	}
+
+	opts := converter.Options{
+		PageSize:          pageSize,
+		Margins:           margins,
+		Landscape:         landscape,
+		PrintBG:           !noBG,
+		Scale:             scale,
+		Bookmarks:         bookmarks && !noBookmark,
+		TitlePage:         titlePage,
+		FixedLayout:       fixedMode,
+		Header:            preset.Header,
+		Footer:            preset.Footer,
+		TOCPage:           tocPage,
+		PreferCSSPageSize: cssPage,
+		Theme:             theme,
+		FontFamily:        fontFamily,
+		FontSize:          fontSize,
+		LineHeight:        lineHeight,
+		UserCSS:           stylesheets,
+		NoBookCSS:         noBookCSS,
+		Print:             printMode,
+		Bleed:             bleedSize,
+		CropMarks:         cropMarks,
+		Timeout:           timeout,
+		Chrome:            chromeOptions(),
+	}
+	if verbose {
+		opts.Progress = func(step string) { fmt.Println(step) }
+	}
+	return opts, nil
+}
+
+// parseMargins combines --margin with the per-side margin flags
+func parseMargins() (converter.Margins, error) {
+	margins, err := converter.ParseMargins(margin)
+	if err != nil {
+		return converter.Margins{}, fmt.Errorf("--margin: %w", err)
+	}
+
+	sides := []struct {
+		flag  string
+		value string
+		side  *float64
+	}{
+		{"margin-top", marginTop, &margins.Top},
+		{"margin-bottom", marginBot, &margins.Bottom},
+		{"margin-inside", marginIn, &margins.Inside},
+		{"margin-outside", marginOut, &margins.Outside},
+	}
+	for _, s := range sides {
+		if s.value == "" {
+			continue
+		}
+		if *s.side, err = converter.ParseLength(s.value); err != nil {
+			return converter.Margins{}, fmt.Errorf("--%s: %w", s.flag, err)
+		}
+	}
+	return margins, nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+)
+
+func TestParseMargins(t *testing.T) {
+	tests := []struct {
+		name              string
+		all               string
+		top, bot, in, out string
+		want              converter.Margins
+		err               string
+	}{
+		{name: "default", all: "0.5in", want: converter.UniformMargins(0.5)},
+		{name: "units", all: "12.7mm", want: converter.UniformMargins(0.5)},
+		{name: "two values", all: "1in 2cm", want: converter.Margins{Top: 1, Bottom: 1, Inside: 0.787, Outside: 0.787}},
+		{name: "four values", all: "1 2 3 4", want: converter.Margins{Top: 1, Outside: 2, Bottom: 3, Inside: 4}},
+		{name: "sides override", all: "0.5in", in: "0.875in", top: "36pt",
+			want: converter.Margins{Top: 0.5, Bottom: 0.5, Inside: 0.875, Outside: 0.5}},
+		{name: "sides override shorthand", all: "1 2", out: "1cm",
+			want: converter.Margins{Top: 1, Bottom: 1, Inside: 2, Outside: 0.394}},
+		{name: "bad margin", all: "wide", err: "--margin"},
+		{name: "too many values", all: "1 2 3 4 5", err: "--margin"},
+		{name: "bad side", all: "0.5", bot: "1px", err: "--margin-bottom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			margin, marginTop, marginBot, marginIn, marginOut = tt.all, tt.top, tt.bot, tt.in, tt.out
+			got, err := parseMargins()
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err+":") {
+					t.Fatalf("parseMargins() error = %v, want one about %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, side := range [][2]float64{
+				{got.Top, tt.want.Top}, {got.Bottom, tt.want.Bottom},
+				{got.Inside, tt.want.Inside}, {got.Outside, tt.want.Outside},
+			} {
+				if math.Abs(side[0]-side[1]) > 1e-3 {
+					t.Fatalf("parseMargins() = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+	margin, marginTop, marginBot, marginIn, marginOut = "0.5in", "", "", "", ""
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/vib795/epub2pdf/internal/converter"
@@ -12,14 +16,12 @@ import (
 )
 
 var (
-	// Flags
+	// Flags of the root command only; the conversion flags are in options.go
 	outputPath string
-	pageSize   string
-	margin     float64
-	landscape  bool
-	noBG       bool
-	scale      float64
-	verbose    bool
+	chapters   string
+	exclude    string
+	pages      string
+	split      string
 )
 
 var rootCmd = &cobra.Command{
@@ -34,26 +36,55 @@ Examples:
   epub2pdf book.epub                    # Output: book.pdf
   epub2pdf book.epub -o output.pdf      # Specify output path
   epub2pdf book.epub --page-size Letter # Use US Letter size
+  epub2pdf book.epub --page-size 6x9    # Book trim size
+  epub2pdf book.epub -p 148x210mm -m 15mm # Custom size and margins
   epub2pdf book.epub --landscape        # Landscape orientation
+  epub2pdf book.epub --no-bookmarks     # Skip the PDF outline
+  epub2pdf book.epub --title-page none  # Start with the first chapter
+  epub2pdf comic.epub --fixed-layout viewport # Keep each page's own size
+  epub2pdf book.epub --headers book     # Title, chapter and page numbers
+  epub2pdf book.epub --toc-page         # Printed contents with page numbers
+  epub2pdf book.epub --prefer-css-page-size # Use the book's own @page size and margins
+  epub2pdf book.epub --theme sans --font-size 12pt --line-height 1.4
+  epub2pdf book.epub --no-book-css --css house.css # Restyle completely
+  epub2pdf book.epub --chapters 3-7,12 --title-page none # Just some chapters
+  epub2pdf book.epub --exclude "/^(Copyright|Index)$/" --pages 1-50
+  epub2pdf book.epub --split chapter -o book/ # One PDF per chapter
+  epub2pdf book.epub --footer "|{page} of {pages}|"
+  epub2pdf book.epub -p 6x9 --print --bleed 0.125in --crop-marks
   epub2pdf book.epub -v                 # Verbose output`,
-	Args: cobra.MinimumNArgs(1),
+	Args: cobra.RangeArgs(1, 2),
 	RunE: runConvert,
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// Ctrl-C or SIGTERM cancels the running conversion, and deferred
+	// cleanup shuts Chrome down. A second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	interrupted := ctx.Err() != nil
+	stop()
+	if err != nil && interrupted {
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(130)
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
 	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output PDF path (default: input name with .pdf extension)")
-	rootCmd.Flags().StringVarP(&pageSize, "page-size", "p", "A4", "Page size: A4, A5, Letter, Legal, Tabloid")
-	rootCmd.Flags().Float64VarP(&margin, "margin", "m", 0.5, "Page margin in inches")
-	rootCmd.Flags().BoolVarP(&landscape, "landscape", "l", false, "Use landscape orientation")
-	rootCmd.Flags().BoolVar(&noBG, "no-background", false, "Don't print background graphics")
-	rootCmd.Flags().Float64VarP(&scale, "scale", "s", 1.0, "Scale factor (0.1 - 2.0)")
-	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
+	addConversionFlags(rootCmd)
+	rootCmd.Flags().StringVar(&chapters, "chapters", "", "Chapters to convert: numbers and ranges as listed by info (3-7,12), titles or /regex/")
+	rootCmd.Flags().StringVar(&exclude, "exclude", "", "Chapters to leave out, same syntax as --chapters")
+	rootCmd.Flags().StringVar(&pages, "pages", "", "Pages of the rendered PDF to keep, e.g. 1-5,8,11-13")
+	rootCmd.Flags().StringVar(&split, "split", "", "Write one PDF per chapter, per TOC section or per N pages into the output directory: chapter, toc-level-N or pages:N")
 }
 
 func runConvert(cmd *cobra.Command, args []string) error {
@@ -68,25 +99,42 @@ func runConvert(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("input file must be an EPUB file")
 	}
 
-	// Determine output path
+	// Determine output path: a directory when splitting
+	var splitMode converter.Split
+	if split != "" {
+		var err error
+		if splitMode, err = converter.ParseSplit(split); err != nil {
+			return err
+		}
+	}
 	output := outputPath
+	if output == "" && len(args) > 1 {
+		output = args[1]
+	}
 	if output == "" {
 		base := strings.TrimSuffix(inputPath, filepath.Ext(inputPath))
 		output = base + ".pdf"
+		if split != "" {
+			output = base
+		}
 	}
 
-	// Validate scale
-	if scale < 0.1 || scale > 2.0 {
-		return fmt.Errorf("scale must be between 0.1 and 2.0")
+	opts, err := conversionOptions(cmd)
+	if err != nil {
+		return err
+	}
+	opts.PageRanges = pages
+	if err := opts.Validate(); err != nil {
+		return err
 	}
 
-	// Validate page size
-	validSizes := map[string]bool{
-		"A4": true, "A5": true, "A3": true,
-		"Letter": true, "Legal": true, "Tabloid": true,
+	include, err := epub.ParseChapterFilter(chapters)
+	if err != nil {
+		return fmt.Errorf("--chapters: %w", err)
 	}
-	if !validSizes[pageSize] {
-		return fmt.Errorf("invalid page size: %s (valid: A4, A5, A3, Letter, Legal, Tabloid)", pageSize)
+	excluded, err := epub.ParseChapterFilter(exclude)
+	if err != nil {
+		return fmt.Errorf("--exclude: %w", err)
 	}
 
 	if verbose {
@@ -96,7 +144,16 @@ func runConvert(cmd *cobra.Command, args []string) error {
 		if landscape {
 			fmt.Print(" (landscape)")
 		}
+		if cssPage {
+			fmt.Print(" (unless the book sets its own)")
+		}
 		fmt.Println()
+		if theme != "" {
+			fmt.Printf("🎨 Theme:  %s\n", theme)
+		}
+		if printMode {
+			fmt.Printf("🖨️  Print:  bleed %gin, crop marks %t\n", opts.Bleed, cropMarks)
+		}
 	}
 
 	// Parse EPUB
@@ -108,11 +165,26 @@ func runConvert(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse EPUB: %w", err)
 	}
+	defer book.Close()
+
+	total := len(book.Chapters)
+	if !include.Empty() || !excluded.Empty() {
+		if err := book.SelectChapters(include, excluded); err != nil {
+			return err
+		}
+	}
 
 	if verbose {
 		fmt.Printf("📚 Title:    %s\n", book.Title)
 		fmt.Printf("✍️  Author:   %s\n", book.Author)
-		fmt.Printf("📑 Chapters: %d\n", len(book.Chapters))
+		if len(book.Chapters) < total {
+			fmt.Printf("📑 Chapters: %d of %d\n", len(book.Chapters), total)
+		} else {
+			fmt.Printf("📑 Chapters: %d\n", total)
+		}
+		if book.FixedLayout {
+			fmt.Printf("🖼️  Layout:   fixed (%s)\n", fixedMode)
+		}
 	}
 
 	// Convert to PDF
@@ -120,17 +192,25 @@ func runConvert(cmd *cobra.Command, args []string) error {
 		fmt.Println("🔄 Converting to PDF...")
 	}
 
-	opts := converter.Options{
-		PageSize:  pageSize,
-		Margin:    margin,
-		Landscape: landscape,
-		PrintBG:   !noBG,
-		Scale:     scale,
-		Verbose:   verbose,
+	if split != "" {
+		paths, warnings, err := converter.ConvertSplit(cmd.Context(), book, output, splitMode, opts)
+		printWarnings(inputPath, warnings)
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil {
+				fmt.Printf("   %s (%s)\n", path, formatFileSize(info.Size()))
+			}
+		}
+		if err != nil {
+			return conversionError(err)
+		}
+		fmt.Printf("✅ Successfully created %d PDFs in %s\n", len(paths), output)
+		return nil
 	}
 
-	if err := converter.Convert(book, output, opts); err != nil {
-		return fmt.Errorf("conversion failed: %w", err)
+	result, err := converter.Convert(cmd.Context(), book, output, opts)
+	printWarnings(inputPath, result.Warnings)
+	if err != nil {
+		return conversionError(err)
 	}
 
 	// Get file size
@@ -145,6 +225,23 @@ func runConvert(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printWarnings prints the problems that didn't stop a conversion to
+// stderr, naming the book they come from
+func printWarnings(input string, warnings []string) {
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "⚠️  %s: %s\n", input, warning)
+	}
+}
+
+// conversionError wraps a failed conversion, suggesting --timeout when it
+// ran out of time
+func conversionError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("conversion failed: %w (raise --timeout, or set it to 0 for no limit)", err)
+	}
+	return fmt.Errorf("conversion failed: %w", err)
+}
+
 func formatFileSize(size int64) string {
 	const (
 		KB = 1024
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vib795/epub2pdf/internal/converter"
+	"github.com/vib795/epub2pdf/internal/server"
+)
+
+var (
+	serveAddr        string
+	serveConcurrency int
+	serveJobTimeout  time.Duration
+	serveMaxUpload   int64
+	serveResultTTL   time.Duration
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP conversion server",
+	Long: `Run an HTTP server converting uploaded EPUB files with one warm
+headless Chrome, a limited number of books at a time.
+
+Endpoints:
+  POST /convert        Convert and respond with the PDF
+  POST /jobs           Start a conversion in the background (202 + job)
+  GET  /jobs/{id}      Job status: queued, running, done or failed
+  GET  /jobs/{id}/pdf  The finished job's PDF
+  GET  /healthz        Liveness check
+
+Send the EPUB as multipart/form-data in the "epub" field, with options
+as form fields named after the conversion flags (page-size, margin,
+theme, chapters, ...) or as a JSON object in an "options" field. The
+"css" option takes stylesheet text. Alternatively send the EPUB as the
+request body with options in the query string.
+
+There is no authentication. The server listens on localhost only unless
+--addr says otherwise; put it behind an authenticating proxy before
+exposing it. Uploaded books print with scripts disabled and can't load
+anything but their own resources.
+
+Examples:
+  epub2pdf serve --concurrency 8
+  epub2pdf serve --addr 10.0.0.5:8080   # Reachable from the private network
+  curl -F epub=@book.epub -F page-size=Letter localhost:8080/convert -o book.pdf
+  curl -F epub=@book.epub -F 'options={"theme":"sans","toc-page":true}' localhost:8080/jobs
+  curl --data-binary @book.epub 'localhost:8080/convert?headers=book' -o book.pdf`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "localhost:8080", "Address to listen on; :8080 for all interfaces")
+	serveCmd.Flags().IntVar(&serveConcurrency, "concurrency", min(4, runtime.NumCPU()), "Number of books converted at once")
+	serveCmd.Flags().DurationVar(&serveJobTimeout, "job-timeout", converter.DefaultTimeout, "Time limit for one conversion")
+	serveCmd.Flags().Int64Var(&serveMaxUpload, "max-upload", 200, "Largest accepted EPUB in MB")
+	serveCmd.Flags().DurationVar(&serveResultTTL, "result-ttl", time.Hour, "How long finished jobs and their PDFs are kept")
+	addChromeFlags(serveCmd)
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if serveConcurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	if serveJobTimeout <= 0 || serveMaxUpload <= 0 || serveResultTTL <= 0 {
+		return fmt.Errorf("--job-timeout, --max-upload and --result-ttl must be positive")
+	}
+
+	// The browser outlives the signal, so running conversions can finish
+	browser, err := converter.NewBrowser(context.Background(), chromeOptions())
+	if err != nil {
+		return err
+	}
+	defer browser.Close()
+
+	srv := server.New(browser, server.Config{
+		Concurrency: serveConcurrency,
+		JobTimeout:  serveJobTimeout,
+		MaxUpload:   serveMaxUpload << 20,
+		ResultTTL:   serveResultTTL,
+	})
+	defer srv.Close()
+
+	httpServer := &http.Server{
+		Addr:              serveAddr,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- httpServer.ListenAndServe()
+	}()
+	fmt.Printf("🌐 Serving on %s (%d at a time)\n", serveAddr, serveConcurrency)
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("server failed: %w", err)
+	case <-cmd.Context().Done():
+	}
+
+	// Let running conversions finish
+	fmt.Println("🛑 Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveJobTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("shutdown failed: %w", err)
+	}
+	return nil
+}
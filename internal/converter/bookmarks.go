@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"github.com/vib795/epub2pdf/internal/epub"
+	"github.com/vib795/epub2pdf/internal/pdf"
+)
+
+// addBookmarks writes a document outline mirroring the book's TOC. Chrome
+// turns every linked anchor into a named destination, so each TOC entry is
+// pointed at the destination of its anchor in the merged document.
+func addBookmarks(doc *pdf.Document, book *epub.Book) error {
+	if len(book.TOC) == 0 {
+		return nil
+	}
+
+	dests, err := doc.NamedDests()
+	if err != nil {
+		return err
+	}
+
+	items := outlineItems(book, book.TOC, dests)
+	if len(items) == 0 {
+		return nil
+	}
+	return doc.SetOutline(items)
+}
+
+// outlineItems converts TOC entries to bookmarks. Entries whose target did
+// not make it into the PDF are dropped and their children promoted.
+func outlineItems(book *epub.Book, entries []epub.TOCEntry, dests map[string]pdf.Array) []pdf.OutlineItem {
+	var items []pdf.OutlineItem
+	for _, entry := range entries {
+		children := outlineItems(book, entry.Children, dests)
+
+		dest, ok := dests[book.Anchor(entry.Href, entry.Fragment)]
+		if !ok {
+			// Fall back to the start of the chapter
+			dest, ok = dests[book.Anchor(entry.Href, "")]
+		}
+		if !ok && len(children) > 0 {
+			// Headings without a target (e.g. "Part II") open their first child
+			dest, ok = children[0].Dest, true
+		}
+		if !ok || entry.Label == "" {
+			items = append(items, children...)
+			continue
+		}
+
+		items = append(items, pdf.OutlineItem{
+			Title:    entry.Label,
+			Dest:     dest,
+			Children: children,
+		})
+	}
+	return items
+}
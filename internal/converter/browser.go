@@ -0,0 +1,303 @@
+package converter
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+
+	"github.com/vib795/epub2pdf/internal/epub"
+)
+
+// Browser is a headless Chrome shared by conversions. Each conversion runs
+// in a tab of its own, so a Browser may be used from several goroutines.
+type Browser struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	path   string
+	remote bool // Chrome runs elsewhere and can't reach our loopback
+
+	mu      sync.Mutex
+	product string // Cached Version
+}
+
+// MinHeaderVersion is the first Chrome version supporting the @page margin
+// boxes headers and footers are printed in. Older versions would silently
+// leave them out, so conversions with headers or footers fail instead.
+const MinHeaderVersion = 131
+
+// MajorVersion returns the major version of a Chrome product such as
+// "HeadlessChrome/131.0.6778.85", or 0
+func MajorVersion(product string) int {
+	_, version, _ := strings.Cut(product, "/")
+	major, _, _ := strings.Cut(version, ".")
+	n, _ := strconv.Atoi(major)
+	return n
+}
+
+// NewBrowser starts headless Chrome, found and launched as chrome says,
+// or connects to chrome.Remote. The browser is killed when ctx is done, as
+// well as by Close; a remote browser is only disconnected from.
+func NewBrowser(ctx context.Context, chrome ChromeOptions) (*Browser, error) {
+	if chrome.Remote != "" {
+		return connectBrowser(ctx, chrome.Remote)
+	}
+
+	path, err := FindChrome(chrome.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	allocOpts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.ExecPath(path))
+	for _, flag := range chrome.Flags {
+		name, value := parseChromeFlag(flag)
+		allocOpts = append(allocOpts, chromedp.Flag(name, value))
+	}
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, allocOpts...)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	b := &Browser{ctx: ctx, path: path, cancel: func() {
+		cancel()
+		allocCancel()
+	}}
+
+	// Start the browser now, so a missing Chrome is reported here
+	if err := chromedp.Run(ctx); err != nil {
+		b.cancel()
+		return nil, fmt.Errorf("failed to start Chrome at %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// connectBrowser connects to the Chrome listening for DevTools clients at
+// url. Conversions get tabs of their own, which are closed when done; the
+// browser itself is left running.
+func connectBrowser(ctx context.Context, url string) (*Browser, error) {
+	allocCtx, allocCancel := chromedp.NewRemoteAllocator(ctx, url)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	b := &Browser{ctx: ctx, path: url, remote: true, cancel: func() {
+		cancel()
+		allocCancel()
+	}}
+
+	if err := chromedp.Run(ctx); err != nil {
+		b.cancel()
+		return nil, fmt.Errorf("failed to connect to Chrome at %s: %w", url, err)
+	}
+	return b, nil
+}
+
+// Path returns the Chrome executable, or the DevTools URL of a remote
+// Chrome
+func (b *Browser) Path() string {
+	return b.path
+}
+
+// Version returns Chrome's product and version, e.g.
+// "HeadlessChrome/131.0.6778.85"
+func (b *Browser) Version(ctx context.Context) (string, error) {
+	c := chromedp.FromContext(b.ctx)
+	_, product, _, _, _, err := browser.GetVersion().Do(cdp.WithExecutor(ctx, c.Browser))
+	return product, err
+}
+
+// cachedVersion returns Version, asking Chrome only until it answers
+func (b *Browser) cachedVersion(ctx context.Context) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.product == "" {
+		b.product, _ = b.Version(ctx)
+	}
+	return b.product
+}
+
+// Close shuts the browser down and waits for Chrome to exit and its
+// profile directory to be removed. A remote browser only loses its tabs
+// and the connection: chromedp.Cancel would close the browser itself.
+func (b *Browser) Close() {
+	if !b.remote {
+		chromedp.Cancel(b.ctx)
+	}
+	b.cancel()
+}
+
+// PrintToPDF loads the document in a new tab and prints it
+func (b *Browser) PrintToPDF(ctx context.Context, url string, params PrintParams) ([]byte, error) {
+	return b.printToPDF(ctx, url, params, false)
+}
+
+// Sandboxed returns a Renderer printing with b for documents that can't be
+// trusted, such as uploaded books: their scripts don't run, and they load
+// nothing but the document and the resources under its URL, so they can't
+// reach other hosts or services on the loopback interface
+func (b *Browser) Sandboxed() Renderer {
+	return sandboxed{b}
+}
+
+type sandboxed struct {
+	browser *Browser
+}
+
+func (s sandboxed) PrintToPDF(ctx context.Context, url string, params PrintParams) ([]byte, error) {
+	return s.browser.printToPDF(ctx, url, params, true)
+}
+
+// printToPDF prints the document at url in a new tab. With untrusted,
+// requests outside url fail and scripts are disabled.
+func (b *Browser) printToPDF(ctx context.Context, url string, params PrintParams, untrusted bool) ([]byte, error) {
+	// The tab belongs to the browser, but closes when ctx is done
+	tab, cancel := chromedp.NewContext(b.ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	printToPDF := page.PrintToPDF().
+		WithPaperWidth(params.PaperWidth).
+		WithPaperHeight(params.PaperHeight).
+		WithMarginTop(params.MarginTop).
+		WithMarginBottom(params.MarginBottom).
+		WithMarginLeft(params.MarginLeft).
+		WithMarginRight(params.MarginRight).
+		WithPrintBackground(params.PrintBackground).
+		WithScale(params.Scale).
+		WithPreferCSSPageSize(params.PreferCSSPageSize).
+		WithPageRanges(params.PageRanges)
+
+	// A remote Chrome can't reach the book server on our loopback, so its
+	// requests for the book are answered from here over the DevTools
+	// connection
+	var actions []chromedp.Action
+	var proxyMu sync.Mutex
+	var proxyErr error // The first request that couldn't be answered
+	if b.remote || untrusted {
+		actions = append(actions, interceptRequests(tab, url, b.remote, untrusted, func(err error) {
+			proxyMu.Lock()
+			defer proxyMu.Unlock()
+			if proxyErr == nil {
+				proxyErr = err
+			}
+		}))
+	}
+	if untrusted {
+		actions = append(actions, emulation.SetScriptExecutionDisabled(true))
+	}
+
+	var pdfData []byte
+	err := chromedp.Run(tab, append(actions,
+		chromedp.Navigate(url),
+		chromedp.WaitReady("body"),
+		waitForFonts(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			pdfData, _, err = printToPDF.Do(ctx)
+			return err
+		}),
+	)...)
+	if ctx.Err() != nil {
+		// Report why the tab was closed rather than that it was
+		return nil, ctx.Err()
+	}
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	if err == nil && proxyErr != nil {
+		return nil, proxyErr
+	}
+	return pdfData, err
+}
+
+// interceptRequests pauses the tab's requests. Those for URLs under
+// prefix are fulfilled by fetching the URL locally with proxy, or let
+// through; with block, all others fail. Responses too large to send are
+// passed to tooLarge.
+func interceptRequests(tab context.Context, prefix string, proxy, block bool, tooLarge func(error)) chromedp.Action {
+	pattern := prefix + "*"
+	if block {
+		pattern = "*"
+	}
+	chromedp.ListenTarget(tab, func(ev any) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// Commands can't be sent from the event loop
+		switch {
+		case !strings.HasPrefix(paused.Request.URL, prefix):
+			go chromedp.Run(tab, fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient))
+		case proxy:
+			go fulfillRequest(tab, paused, prefix, tooLarge)
+		default:
+			go chromedp.Run(tab, fetch.ContinueRequest(paused.RequestID))
+		}
+	})
+	return fetch.Enable().WithPatterns([]*fetch.RequestPattern{{URLPattern: pattern}})
+}
+
+// maxProxiedResponse is the largest response sent to a remote Chrome. The
+// DevTools protocol takes a response body whole, base64-encoded in a
+// single message, so it is held in memory while it is sent.
+const maxProxiedResponse = 64 << 20
+
+// fulfillRequest answers a paused request with the local response.
+// Responses over maxProxiedResponse fail and are passed to tooLarge.
+func fulfillRequest(tab context.Context, ev *fetch.EventRequestPaused, prefix string, tooLarge func(error)) {
+	req, err := http.NewRequestWithContext(tab, ev.Request.Method, ev.Request.URL, nil)
+	if err != nil {
+		chromedp.Run(tab, fetch.FailRequest(ev.RequestID, network.ErrorReasonFailed))
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		chromedp.Run(tab, fetch.FailRequest(ev.RequestID, network.ErrorReasonConnectionFailed))
+		return
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	if resp.ContentLength <= maxProxiedResponse {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxProxiedResponse+1))
+		if err != nil {
+			chromedp.Run(tab, fetch.FailRequest(ev.RequestID, network.ErrorReasonFailed))
+			return
+		}
+	}
+	if resp.ContentLength > maxProxiedResponse || len(body) > maxProxiedResponse {
+		name := strings.TrimPrefix(strings.TrimPrefix(ev.Request.URL, prefix), epub.ResourcePrefix)
+		if name == "" {
+			name = "the merged document"
+		}
+		tooLarge(fmt.Errorf("%s is over the %d MB limit for files sent to a remote Chrome: convert the book with a local Chrome", name, maxProxiedResponse>>20))
+		chromedp.Run(tab, fetch.FailRequest(ev.RequestID, network.ErrorReasonFailed))
+		return
+	}
+
+	var headers []*fetch.HeaderEntry
+	for name, values := range resp.Header {
+		for _, value := range values {
+			headers = append(headers, &fetch.HeaderEntry{Name: name, Value: value})
+		}
+	}
+	chromedp.Run(tab, fetch.FulfillRequest(ev.RequestID, int64(resp.StatusCode)).
+		WithResponseHeaders(headers).
+		WithBody(base64.StdEncoding.EncodeToString(body)))
+}
+
+// waitForFonts waits until the web fonts the book uses have loaded, as
+// they are fetched on demand and may still be loading after the load event
+func waitForFonts() chromedp.Action {
+	return chromedp.Evaluate(`document.fonts.ready.then(() => true)`, nil,
+		func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		})
+}
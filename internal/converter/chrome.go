@@ -0,0 +1,146 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ChromeEnv names the environment variable giving the Chrome executable
+// when ChromeOptions.Path is empty
+const ChromeEnv = "EPUB2PDF_CHROME"
+
+// ChromeOptions choose and launch the Chrome executable
+type ChromeOptions struct {
+	// Path is the executable, a path or a name looked up in PATH. When
+	// empty, $EPUB2PDF_CHROME is used, then the usual install locations
+	// are searched.
+	Path string
+
+	// Flags are extra command-line flags such as "no-sandbox" or
+	// "--window-size=800,600". "name=false" removes a default flag; when
+	// running as root, no-sandbox is one.
+	Flags []string
+
+	// Remote is the DevTools URL of an already running Chrome to use
+	// instead, e.g. "ws://localhost:9222" or "http://chrome:9222". Path
+	// and Flags are then ignored.
+	Remote string
+}
+
+// Flag returns the value of the last of Flags named name, as passed to
+// Chrome, and whether there is one
+func (o ChromeOptions) Flag(name string) (any, bool) {
+	var value any
+	found := false
+	for _, flag := range o.Flags {
+		if n, v := parseChromeFlag(flag); n == name {
+			value, found = v, true
+		}
+	}
+	return value, found
+}
+
+// BrowserNotFoundError reports that no Chrome executable was found
+type BrowserNotFoundError struct {
+	Requested string   // The executable asked for, if any
+	Searched  []string // Paths and names looked up in PATH, in order
+}
+
+func (e *BrowserNotFoundError) Error() string {
+	if e.Requested != "" {
+		return fmt.Sprintf("Chrome not found at %s", e.Requested)
+	}
+	return fmt.Sprintf("Chrome not found (searched: %s); install Chrome or Chromium, or set %s to its path",
+		strings.Join(e.Searched, ", "), ChromeEnv)
+}
+
+// chromeCandidates returns the executables to look for, headless shells
+// first as they start faster and need no desktop libraries
+func chromeCandidates() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		home, _ := os.UserHomeDir()
+		return []string{
+			"chrome-headless-shell",
+			"headless-shell",
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+			"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+			filepath.Join(home, "Applications/Google Chrome.app/Contents/MacOS/Google Chrome"),
+			"google-chrome",
+			"chromium",
+		}
+	case "windows":
+		var paths []string
+		for _, dir := range []string{os.Getenv("ProgramFiles"), os.Getenv("ProgramFiles(x86)"), os.Getenv("LocalAppData")} {
+			if dir == "" {
+				continue
+			}
+			paths = append(paths,
+				filepath.Join(dir, `Google\Chrome\Application\chrome.exe`),
+				filepath.Join(dir, `Chromium\Application\chrome.exe`),
+				filepath.Join(dir, `Microsoft\Edge\Application\msedge.exe`),
+			)
+		}
+		return append([]string{"chrome-headless-shell.exe", "chrome.exe"}, paths...)
+	default:
+		return []string{
+			"chrome-headless-shell",
+			"headless-shell",
+			"headless_shell",
+			"/headless-shell/headless-shell", // chromedp/headless-shell image
+			"google-chrome",
+			"google-chrome-stable",
+			"chromium",
+			"chromium-browser",
+			"/snap/bin/chromium",
+			"/usr/lib/chromium/chromium",
+			"/opt/google/chrome/chrome",
+			"google-chrome-beta",
+			"google-chrome-unstable",
+			"microsoft-edge",
+			"chrome",
+		}
+	}
+}
+
+// FindChrome returns the absolute path of the Chrome executable: path if
+// set, else $EPUB2PDF_CHROME, else the first of the usual locations that
+// exists. The error is a *BrowserNotFoundError.
+func FindChrome(path string) (string, error) {
+	if path == "" {
+		path = os.Getenv(ChromeEnv)
+	}
+	candidates := chromeCandidates()
+	if path != "" {
+		// Don't fall back to another browser than the one asked for
+		candidates = []string{path}
+	}
+
+	for _, candidate := range candidates {
+		if found, err := exec.LookPath(candidate); err == nil {
+			if abs, err := filepath.Abs(found); err == nil {
+				found = abs
+			}
+			return found, nil
+		}
+	}
+	return "", &BrowserNotFoundError{Requested: path, Searched: candidates}
+}
+
+// parseChromeFlag splits a flag such as "--window-size=800,600" into its
+// name and value for chromedp.Flag. Flags without a value are true.
+func parseChromeFlag(flag string) (string, any) {
+	name, value, ok := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+	switch {
+	case !ok, value == "true":
+		return name, true
+	case value == "false":
+		return name, false
+	}
+	return name, value
+}
@@ -2,124 +2,432 @@ package converter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/chromedp/cdproto/page"
-	"github.com/chromedp/chromedp"
 	"github.com/vib795/epub2pdf/internal/epub"
+	"github.com/vib795/epub2pdf/internal/pdf"
 )
 
 // Options holds conversion options
 type Options struct {
-	PageSize    string  // A4, Letter, etc.
-	Margin      float64 // Margin in inches
+	PageSize    string  // A4, Letter, 6x9, "148x210mm", etc.
+	Margins     Margins // Margins in inches
 	Landscape   bool
 	PrintBG     bool // Print background graphics
 	Scale       float64
-	Verbose     bool
+	Bookmarks   bool   // Generate a PDF outline from the book's TOC
+	TitlePage   string // cover, generated or none
+	FixedLayout string // fit or viewport: how pre-paginated pages are sized
+	Header      string // Header template ("left|center|right"), empty for none
+	Footer      string // Footer template
+	TOCPage     bool   // Print a table of contents with page numbers
+
+	// PreferCSSPageSize lets the book's own @page rules set the page size
+	// and margins, falling back to PageSize and Margins
+	PreferCSSPageSize bool
+
+	// Typography: a theme (serif, sans, large-print, dyslexia-friendly,
+	// e-ink) and overrides of its font family, size and line height
+	Theme      string
+	FontFamily string  // CSS font-family list
+	FontSize   string  // CSS length such as 12pt or 1.2em
+	LineHeight float64 // Multiple of the font size, 0 for the theme's
+
+	UserCSS   []string // Stylesheets applied after the book's, as CSS text
+	NoBookCSS bool     // Drop the book's own stylesheets
+
+	PageRanges string // Pages to keep, e.g. "1-5, 8, 11-13"; empty for all
+
+	// Print-ready output: mirrored margins, chapters on right-hand pages,
+	// Bleed inches of bleed around the trimmed page and optional crop marks
+	Print     bool
+	Bleed     float64
+	CropMarks bool
+
+	Timeout time.Duration // Limit on one conversion, 0 for none
+
+	// Chrome chooses the browser Convert and ConvertSplit start
+	Chrome ChromeOptions
+
+	// Progress, when set, is called with a short description of each step
+	// of a conversion. The converter itself never prints.
+	Progress func(step string)
+}
+
+// Result describes a finished conversion
+type Result struct {
+	Pages    int      // Pages in the PDF, 0 if it couldn't be read back
+	Warnings []string // Problems that didn't stop the conversion
+	Timings  Timings
+}
+
+// Timings break down the time a conversion took
+type Timings struct {
+	Layout      time.Duration // Building the merged HTML document
+	Render      time.Duration // Printing it to PDF, twice with a TOC page
+	PostProcess time.Duration // Bookmarks, metadata and print marks
+	Total       time.Duration
+}
+
+func (r *Result) warn(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
 }
 
+// progress reports a step of the conversion to o.Progress
+func (o Options) progress(format string, args ...any) {
+	if o.Progress != nil {
+		o.Progress(fmt.Sprintf(format, args...))
+	}
+}
+
+// fontSizeRegex matches the CSS lengths accepted as a font size
+var fontSizeRegex = regexp.MustCompile(`^\d*\.?\d+(pt|px|em|rem|%|mm|cm|in|pc)$`)
+
+// pageRangesRegex matches the page ranges Chrome accepts: one-based pages
+// and ranges, possibly open-ended, separated by commas
+var pageRangesRegex = regexp.MustCompile(`^\s*(\d+\s*(-\s*\d*)?|-\s*\d+)\s*(,\s*(\d+\s*(-\s*\d*)?|-\s*\d+)\s*)*$`)
+
+// DefaultTimeout is the default limit on one conversion
+const DefaultTimeout = 2 * time.Minute
+
 // DefaultOptions returns sensible defaults
 func DefaultOptions() Options {
 	return Options{
-		PageSize:  "A4",
-		Margin:    0.5,
-		Landscape: false,
-		PrintBG:   true,
-		Scale:     1.0,
-		Verbose:   false,
+		PageSize:    "A4",
+		Margins:     UniformMargins(0.5),
+		Landscape:   false,
+		PrintBG:     true,
+		Scale:       1.0,
+		Bookmarks:   true,
+		TitlePage:   epub.TitlePageCover,
+		FixedLayout: epub.FixedLayoutFit,
+		Timeout:     DefaultTimeout,
 	}
 }
 
-// Convert converts an EPUB book to PDF
-func Convert(book *epub.Book, outputPath string, opts Options) error {
-	html := book.ToHTML()
+// Validate checks the options, giving library callers the same errors as
+// the command line. The conversion functions expect valid options: callers
+// validate them once, where they are read.
+func (o Options) Validate() error {
+	if o.Scale < 0.1 || o.Scale > 2.0 {
+		return fmt.Errorf("scale must be between 0.1 and 2.0")
+	}
+
+	switch o.TitlePage {
+	case "", epub.TitlePageCover, epub.TitlePageGenerated, epub.TitlePageNone:
+	default:
+		return fmt.Errorf("invalid title page: %s (valid: cover, generated, none)", o.TitlePage)
+	}
 
-	// Create a temporary HTML file
-	tmpFile, err := os.CreateTemp("", "epub2pdf-*.html")
+	switch o.FixedLayout {
+	case "", epub.FixedLayoutFit, epub.FixedLayoutViewport:
+	default:
+		return fmt.Errorf("invalid fixed-layout mode: %s (valid: fit, viewport)", o.FixedLayout)
+	}
+
+	if _, ok := epub.Themes[o.Theme]; o.Theme != "" && !ok {
+		return fmt.Errorf("invalid theme: %s (valid: %s)", o.Theme, strings.Join(epub.ThemeNames(), ", "))
+	}
+	if o.FontSize != "" && !fontSizeRegex.MatchString(o.FontSize) {
+		return fmt.Errorf("invalid font size: %s (use a CSS length such as 12pt, 16px or 1.2em)", o.FontSize)
+	}
+	if strings.ContainsAny(o.FontFamily, ";{}<>") {
+		return fmt.Errorf("invalid font family: %s", o.FontFamily)
+	}
+	if o.LineHeight < 0 || o.LineHeight > 5 {
+		return fmt.Errorf("line height must be between 0 and 5")
+	}
+
+	if o.PageRanges != "" && !pageRangesRegex.MatchString(o.PageRanges) {
+		return fmt.Errorf("invalid page ranges: %s (use e.g. 1-5,8,11-)", o.PageRanges)
+	}
+
+	if o.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	if o.Bleed < 0 {
+		return fmt.Errorf("bleed must not be negative")
+	}
+	if (o.Bleed > 0 || o.CropMarks) && !o.Print {
+		return fmt.Errorf("bleed and crop marks need print mode")
+	}
+	if (o.Bleed > 0 || o.CropMarks) && o.PreferCSSPageSize {
+		return fmt.Errorf("bleed and crop marks need the page size from the options, not the book's @page rules")
+	}
+
+	_, _, err := o.pageLayout()
+	return err
+}
+
+// pageLayout returns the page size, oriented, and the margins, grown to fit
+// the header and footer. In print mode these are the trimmed page's; the
+// paper adds the bleed and slug.
+func (o Options) pageLayout() (PageSize, Margins, error) {
+	size, err := ParsePageSize(o.PageSize)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tmpFile.Name())
-
-	if _, err := tmpFile.WriteString(html); err != nil {
-		tmpFile.Close()
-		return fmt.Errorf("failed to write temp file: %w", err)
-	}
-	tmpFile.Close()
-
-	// Create Chrome context
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
-	// Set timeout
-	ctx, cancel = context.WithTimeout(ctx, 2*time.Minute)
-	defer cancel()
-
-	// Navigate and print to PDF
-	var pdfData []byte
-	
-	fileURL := "file://" + tmpFile.Name()
-	
-	if opts.Verbose {
-		fmt.Printf("Converting HTML to PDF using headless Chrome...\n")
-	}
-
-	// Get page dimensions based on page size
-	width, height := getPageDimensions(opts.PageSize)
-	if opts.Landscape {
-		width, height = height, width
-	}
-
-	err = chromedp.Run(ctx,
-		chromedp.Navigate(fileURL),
-		chromedp.WaitReady("body"),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			var err error
-			pdfData, _, err = page.PrintToPDF().
-				WithPaperWidth(width).
-				WithPaperHeight(height).
-				WithMarginTop(opts.Margin).
-				WithMarginBottom(opts.Margin).
-				WithMarginLeft(opts.Margin).
-				WithMarginRight(opts.Margin).
-				WithPrintBackground(opts.PrintBG).
-				WithScale(opts.Scale).
-				Do(ctx)
-			return err
-		}),
-	)
+		return PageSize{}, Margins{}, err
+	}
+	if o.Landscape {
+		size.Width, size.Height = size.Height, size.Width
+	}
+
+	m := o.Margins
+	if m.Top < 0 || m.Bottom < 0 || m.Inside < 0 || m.Outside < 0 {
+		return PageSize{}, Margins{}, fmt.Errorf("margins must not be negative")
+	}
 
+	// Headers and footers are drawn in the page margins, which must be
+	// tall enough to hold them
+	m.Top = max(m.Top, epub.HeaderFooterHeight(o.Header))
+	m.Bottom = max(m.Bottom, epub.HeaderFooterHeight(o.Footer))
+
+	if m.Top+m.Bottom >= size.Height || m.Inside+m.Outside >= size.Width {
+		return PageSize{}, Margins{}, fmt.Errorf("margins leave no room for content on a %.2fx%.2fin page", size.Width, size.Height)
+	}
+	return size, m, nil
+}
+
+// Convert converts an EPUB book to PDF in a browser of its own, which is
+// shut down when the conversion ends or ctx is done
+func Convert(ctx context.Context, book *epub.Book, outputPath string, opts Options) (Result, error) {
+	browser, err := NewBrowser(ctx, opts.Chrome)
+	if err != nil {
+		return Result{}, err
+	}
+	defer browser.Close()
+	opts.progress("Using Chrome at %s", browser.Path())
+	return browser.Convert(ctx, book, outputPath, opts)
+}
+
+// Convert converts an EPUB book to PDF in a new tab of the browser.
+// Problems that don't stop the conversion are reported in the result for
+// the caller to show.
+func (b *Browser) Convert(ctx context.Context, book *epub.Book, outputPath string, opts Options) (Result, error) {
+	pdfData, result, err := Render(ctx, b, book, opts)
 	if err != nil {
-		return fmt.Errorf("failed to generate PDF: %w", err)
+		return result, err
 	}
 
 	// Write PDF to output file
 	if err := os.WriteFile(outputPath, pdfData, 0644); err != nil {
-		return fmt.Errorf("failed to write PDF: %w", err)
+		return result, fmt.Errorf("failed to write PDF: %w", err)
 	}
 
-	return nil
+	return result, nil
 }
 
-// getPageDimensions returns width and height in inches for common page sizes
-func getPageDimensions(pageSize string) (float64, float64) {
-	switch pageSize {
-	case "Letter":
-		return 8.5, 11
-	case "Legal":
-		return 8.5, 14
-	case "Tabloid":
-		return 11, 17
-	case "A3":
-		return 11.69, 16.54
-	case "A4":
-		return 8.27, 11.69
-	case "A5":
-		return 5.83, 8.27
-	default:
-		return 8.27, 11.69 // Default to A4
+// Render converts an EPUB book to PDF with renderer and returns the PDF.
+// Problems that don't stop the conversion are reported in the result.
+func Render(ctx context.Context, renderer Renderer, book *epub.Book, opts Options) ([]byte, Result, error) {
+	start := time.Now()
+	result := Result{Warnings: append([]string(nil), book.Warnings...)}
+	size, margins, err := opts.pageLayout()
+	if err != nil {
+		return nil, result, err
+	}
+	if opts.PreferCSSPageSize {
+		// Size the cover and fixed-layout pages to the book's pages too
+		if w, h, ok := book.PageSize(); ok {
+			size = PageSize{w, h}
+			opts.progress("Using the book's page size: %.2fx%.2fin", w, h)
+		}
+	}
+	if opts.Header != "" || opts.Footer != "" {
+		if major := MajorVersion(rendererVersion(ctx, renderer)); major > 0 && major < MinHeaderVersion {
+			return nil, result, fmt.Errorf("headers and footers need Chrome %d or later, which prints CSS page-margin boxes; Chrome %d would leave them out: update Chrome or install chrome-headless-shell", MinHeaderVersion, major)
+		}
+	}
+
+	// The paper is the trimmed page surrounded by the bleed and slug, and
+	// the margins grow with it
+	offset := opts.Bleed + opts.slug()
+	paper := PageSize{size.Width + 2*offset, size.Height + 2*offset}
+
+	htmlOpts := epub.DefaultHTMLOptions()
+	if opts.TitlePage != "" {
+		htmlOpts.TitlePage = opts.TitlePage
+	}
+	if opts.FixedLayout != "" {
+		htmlOpts.FixedLayout = opts.FixedLayout
+	}
+	htmlOpts.Header = opts.Header
+	htmlOpts.Footer = opts.Footer
+	htmlOpts.TOCPage = opts.TOCPage
+	htmlOpts.Print = opts.Print
+	htmlOpts.Bleed = opts.Bleed
+	htmlOpts.Slug = opts.slug()
+	htmlOpts.PreferCSSPageSize = opts.PreferCSSPageSize
+	htmlOpts.Theme = opts.Theme
+	htmlOpts.FontFamily = opts.FontFamily
+	htmlOpts.FontSize = opts.FontSize
+	htmlOpts.LineHeight = opts.LineHeight
+	htmlOpts.UserCSS = opts.UserCSS
+	htmlOpts.NoBookCSS = opts.NoBookCSS
+	htmlOpts.InsideMargin = margins.Inside
+	htmlOpts.OutsideMargin = margins.Outside
+	htmlOpts.PageWidth = size.Width
+	htmlOpts.PageHeight = size.Height
+	html := book.ToHTML(htmlOpts)
+
+	result.Timings.Layout = time.Since(start)
+
+	// Serve the document and its resources to the renderer
+	server, err := serveBook(book, html)
+	if err != nil {
+		return nil, result, fmt.Errorf("failed to start book server: %w", err)
+	}
+	defer server.Close()
+
+	parent := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	params := PrintParams{
+		PaperWidth:        paper.Width,
+		PaperHeight:       paper.Height,
+		MarginTop:         margins.Top + offset,
+		MarginBottom:      margins.Bottom + offset,
+		MarginLeft:        margins.Inside + offset,
+		MarginRight:       margins.Outside + offset,
+		PrintBackground:   opts.PrintBG,
+		Scale:             opts.Scale,
+		PreferCSSPageSize: opts.PreferCSSPageSize || htmlOpts.FixedLayout == epub.FixedLayoutViewport,
+	}
+
+	opts.progress("Converting HTML to PDF...")
+
+	// The printed TOC numbers the whole book, so the page ranges only
+	// apply to the last render
+	if !opts.TOCPage {
+		params.PageRanges = opts.PageRanges
+	}
+
+	renderStart := time.Now()
+	pdfData, err := renderer.PrintToPDF(ctx, server.URL, params)
+	if err != nil {
+		return nil, result, renderError(parent, err, opts.Timeout)
 	}
+
+	// The printed TOC needs the page numbers of the first render
+	if opts.TOCPage {
+		opts.progress("Filling in table of contents page numbers...")
+		numbers, err := pageNumbers(pdfData)
+		if err != nil {
+			result.warn("could not number the table of contents: %v", err)
+		} else {
+			htmlOpts.PageNumbers = numbers
+			server.SetDocument(book.ToHTML(htmlOpts))
+		}
+		if err == nil || opts.PageRanges != "" {
+			params.PageRanges = opts.PageRanges
+			if pdfData, err = renderer.PrintToPDF(ctx, server.URL, params); err != nil {
+				return nil, result, renderError(parent, err, opts.Timeout)
+			}
+		}
+	}
+	result.Timings.Render = time.Since(renderStart)
+	result.Warnings = append(result.Warnings, server.Warnings()...)
+
+	// Post-processing failures shouldn't fail the whole conversion
+	postStart := time.Now()
+	if processed, pages, err := postProcess(pdfData, book, opts, paper); err != nil {
+		result.warn("could not post-process PDF: %v", err)
+	} else {
+		pdfData = processed
+		result.Pages = pages
+	}
+	result.Timings.PostProcess = time.Since(postStart)
+	result.Timings.Total = time.Since(start)
+
+	return pdfData, result, nil
+}
+
+// rendererVersion returns the Chrome version of a renderer printing with
+// a Browser, or ""
+func rendererVersion(ctx context.Context, renderer Renderer) string {
+	switch r := renderer.(type) {
+	case *Browser:
+		return r.cachedVersion(ctx)
+	case sandboxed:
+		return r.browser.cachedVersion(ctx)
+	}
+	return ""
+}
+
+// renderError describes a failed render, telling running out of time
+// apart from the caller's deadline or cancellation
+func renderError(parent context.Context, err error, timeout time.Duration) error {
+	if errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+		return fmt.Errorf("failed to generate PDF: timed out after %s: %w", timeout, err)
+	}
+	return fmt.Errorf("failed to generate PDF: %w", err)
+}
+
+// pageNumbers maps each named destination of a PDF (the anchors of the
+// merged document) to its one-based page number
+func pageNumbers(pdfData []byte) (map[string]int, error) {
+	doc, err := pdf.Open(pdfData)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := doc.Pages()
+	if err != nil {
+		return nil, err
+	}
+	dests, err := doc.NamedDests()
+	if err != nil {
+		return nil, err
+	}
+
+	index := pdf.PageIndex(pages)
+	numbers := make(map[string]int, len(dests))
+	for name, dest := range dests {
+		if page := pdf.DestPage(dest, index); page >= 0 {
+			numbers[name] = page + 1
+		}
+	}
+	return numbers, nil
+}
+
+// postProcess adds the document features Chrome can't produce itself:
+// bookmarks, full bibliographic metadata and print page boxes
+func postProcess(pdfData []byte, book *epub.Book, opts Options, paper PageSize) ([]byte, int, error) {
+	doc, err := pdf.Open(pdfData)
+	if err != nil {
+		return nil, 0, err
+	}
+	pages, err := doc.Pages()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if opts.Bookmarks {
+		opts.progress("Adding bookmarks...")
+		if err := addBookmarks(doc, book); err != nil {
+			return nil, 0, fmt.Errorf("bookmarks: %w", err)
+		}
+	}
+
+	opts.progress("Writing metadata...")
+	if err := setMetadata(doc, book); err != nil {
+		return nil, 0, fmt.Errorf("metadata: %w", err)
+	}
+
+	if opts.Print {
+		opts.progress("Adding trim and bleed boxes...")
+		if err := addPrintMarks(doc, paper, opts.Bleed, opts.slug(), opts.CropMarks); err != nil {
+			return nil, 0, fmt.Errorf("print marks: %w", err)
+		}
+	}
+
+	return doc.Bytes(), len(pages), nil
 }
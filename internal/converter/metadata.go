@@ -0,0 +1,158 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vib795/epub2pdf/internal/epub"
+	"github.com/vib795/epub2pdf/internal/pdf"
+)
+
+// producer identifies this tool in the PDF Info dictionary and XMP packet
+const producer = "epub2pdf"
+
+// setMetadata writes the book's Dublin Core metadata into the PDF Info
+// dictionary and an XMP packet so document management systems can index it
+func setMetadata(doc *pdf.Document, book *epub.Book) error {
+	meta := book.Metadata
+	now := time.Now()
+
+	title := book.Title
+	if meta.Subtitle != "" {
+		title += ": " + meta.Subtitle
+	}
+
+	info := pdf.Info{
+		Title:        title,
+		Author:       book.Author,
+		Subject:      meta.Description,
+		Keywords:     strings.Join(meta.Subjects, ", "),
+		Creator:      producer,
+		CreationDate: now,
+		ModDate:      now,
+	}
+	if info.Subject == "" {
+		info.Subject = info.Keywords
+	}
+	if err := doc.SetInfo(info); err != nil {
+		return err
+	}
+
+	if len(meta.Languages) > 0 {
+		if err := doc.SetLanguage(meta.Languages[0]); err != nil {
+			return err
+		}
+	}
+
+	return doc.SetXMP(buildXMP(book, title, info.Keywords, now))
+}
+
+// buildXMP renders the metadata as an XMP packet using the Dublin Core,
+// XMP basic and Adobe PDF schemas
+func buildXMP(book *epub.Book, title, keywords string, now time.Time) []byte {
+	meta := book.Metadata
+	var buf bytes.Buffer
+
+	buf.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buf.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	buf.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	buf.WriteString("<rdf:Description rdf:about=\"\"\n")
+	buf.WriteString("  xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	buf.WriteString("  xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n")
+	buf.WriteString("  xmlns:xmpidq=\"http://ns.adobe.com/xmp/Identifier/qual/1.0/\"\n")
+	buf.WriteString("  xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n")
+
+	writeXMPList(&buf, "dc:title", "Alt", []string{title})
+	writeXMPList(&buf, "dc:creator", "Seq", personNames(meta.Authors()))
+
+	// Everyone who isn't an author is a contributor in the XMP sense
+	var contributors []string
+	for _, p := range meta.Creators {
+		if p.Role != "" && p.Role != "aut" {
+			contributors = append(contributors, p.Name)
+		}
+	}
+	contributors = append(contributors, personNames(meta.Contributors)...)
+	writeXMPList(&buf, "dc:contributor", "Bag", contributors)
+
+	if meta.Description != "" {
+		writeXMPList(&buf, "dc:description", "Alt", []string{meta.Description})
+	}
+	writeXMPList(&buf, "dc:subject", "Bag", meta.Subjects)
+	if meta.Publisher != "" {
+		writeXMPList(&buf, "dc:publisher", "Bag", []string{meta.Publisher})
+	}
+	writeXMPList(&buf, "dc:language", "Bag", meta.Languages)
+	if meta.Date != "" {
+		writeXMPList(&buf, "dc:date", "Seq", []string{meta.Date})
+	}
+	if meta.Rights != "" {
+		writeXMPList(&buf, "dc:rights", "Alt", []string{meta.Rights})
+	}
+	if meta.UniqueIdentifier != "" {
+		writeXMPProperty(&buf, "dc:identifier", meta.UniqueIdentifier)
+	}
+
+	if len(meta.Identifiers) > 0 {
+		buf.WriteString("  <xmp:Identifier><rdf:Bag>\n")
+		for _, id := range meta.Identifiers {
+			if id.Scheme == "" {
+				fmt.Fprintf(&buf, "    <rdf:li>%s</rdf:li>\n", xmlEscape(id.Value))
+				continue
+			}
+			fmt.Fprintf(&buf, "    <rdf:li rdf:parseType=\"Resource\"><rdf:value>%s</rdf:value><xmpidq:Scheme>%s</xmpidq:Scheme></rdf:li>\n",
+				xmlEscape(id.Value), xmlEscape(id.Scheme))
+		}
+		buf.WriteString("  </rdf:Bag></xmp:Identifier>\n")
+	}
+
+	stamp := now.Format(time.RFC3339)
+	writeXMPProperty(&buf, "xmp:CreatorTool", producer)
+	writeXMPProperty(&buf, "xmp:CreateDate", stamp)
+	writeXMPProperty(&buf, "xmp:ModifyDate", stamp)
+	writeXMPProperty(&buf, "xmp:MetadataDate", stamp)
+	if keywords != "" {
+		writeXMPProperty(&buf, "pdf:Keywords", keywords)
+	}
+
+	buf.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	buf.WriteString("<?xpacket end=\"w\"?>")
+	return buf.Bytes()
+}
+
+func writeXMPProperty(buf *bytes.Buffer, name, value string) {
+	fmt.Fprintf(buf, "  <%s>%s</%s>\n", name, xmlEscape(value), name)
+}
+
+// writeXMPList writes an rdf:Alt, rdf:Seq or rdf:Bag container
+func writeXMPList(buf *bytes.Buffer, name, container string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "  <%s><rdf:%s>\n", name, container)
+	for _, v := range values {
+		if container == "Alt" {
+			fmt.Fprintf(buf, "    <rdf:li xml:lang=\"x-default\">%s</rdf:li>\n", xmlEscape(v))
+		} else {
+			fmt.Fprintf(buf, "    <rdf:li>%s</rdf:li>\n", xmlEscape(v))
+		}
+	}
+	fmt.Fprintf(buf, "  </rdf:%s></%s>\n", container, name)
+}
+
+func personNames(people []epub.Person) []string {
+	names := make([]string, 0, len(people))
+	for _, p := range people {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,160 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PageSize is a paper size in inches
+type PageSize struct {
+	Width  float64
+	Height float64
+}
+
+// pageSizes are the named paper and book trim sizes
+var pageSizes = map[string]PageSize{
+	// Paper
+	"A3":      {11.69, 16.54},
+	"A4":      {8.27, 11.69},
+	"A5":      {5.83, 8.27},
+	"A6":      {4.13, 5.83},
+	"B5":      {6.93, 9.84},
+	"B6":      {4.92, 6.93},
+	"Letter":  {8.5, 11},
+	"Legal":   {8.5, 14},
+	"Tabloid": {11, 17},
+
+	// Book trims
+	"5x8":     {5, 8},
+	"5.5x8.5": {5.5, 8.5},
+	"6x9":     {6, 9},
+	"Pocket":  {4.25, 6.87},
+}
+
+// PageSizeNames returns the names of the known page sizes
+func PageSizeNames() []string {
+	names := make([]string, 0, len(pageSizes))
+	for name := range pageSizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	lengthRegex   = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?|\.\d+)\s*(in|mm|cm|pt)?\s*$`)
+	sizeSeparator = regexp.MustCompile(`(?i)\s*[x×]\s*`)
+	unitRegex     = regexp.MustCompile(`(?i)^(?:in|mm|cm|pt)$`)
+)
+
+// ParseLength parses a length such as "0.5", "0.5in", "12mm", "1.5cm" or
+// "36pt" and returns it in inches. Bare numbers are inches.
+func ParseLength(s string) (float64, error) {
+	m := lengthRegex.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid length %q (use a number with in, mm, cm or pt)", s)
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid length %q: %w", s, err)
+	}
+
+	switch m[2] {
+	case "mm":
+		value /= 25.4
+	case "cm":
+		value /= 2.54
+	case "pt":
+		value /= 72
+	}
+	return value, nil
+}
+
+// ParsePageSize parses a named size (A4, Letter, 6x9, Pocket, ...) or a
+// custom "WxH" size such as "6x9in", "148x210mm" or "15cm x 23cm". A unit
+// after the height applies to both dimensions.
+func ParsePageSize(s string) (PageSize, error) {
+	for name, size := range pageSizes {
+		if strings.EqualFold(name, strings.TrimSpace(s)) {
+			return size, nil
+		}
+	}
+
+	parts := sizeSeparator.Split(strings.TrimSpace(s), -1)
+	if len(parts) != 2 {
+		return PageSize{}, fmt.Errorf("invalid page size: %s (valid: %s, or WxH with in, mm, cm or pt)", s, strings.Join(PageSizeNames(), ", "))
+	}
+
+	// "148x210mm": the unit of the height applies to the width too
+	width, height := parts[0], parts[1]
+	if m := lengthRegex.FindStringSubmatch(strings.ToLower(height)); m != nil && m[2] != "" {
+		if w := lengthRegex.FindStringSubmatch(strings.ToLower(width)); w != nil && w[2] == "" {
+			width += m[2]
+		}
+	}
+
+	var size PageSize
+	var err error
+	if size.Width, err = ParseLength(width); err != nil {
+		return PageSize{}, fmt.Errorf("invalid page size %s: %w", s, err)
+	}
+	if size.Height, err = ParseLength(height); err != nil {
+		return PageSize{}, fmt.Errorf("invalid page size %s: %w", s, err)
+	}
+	if size.Width <= 0 || size.Height <= 0 {
+		return PageSize{}, fmt.Errorf("invalid page size %s: dimensions must be positive", s)
+	}
+	return size, nil
+}
+
+// Margins are page margins in inches. Inside is the binding edge: the left
+// of right-hand pages and the right of left-hand pages.
+type Margins struct {
+	Top     float64
+	Bottom  float64
+	Inside  float64
+	Outside float64
+}
+
+// UniformMargins returns the same margin on all four sides
+func UniformMargins(margin float64) Margins {
+	return Margins{Top: margin, Bottom: margin, Inside: margin, Outside: margin}
+}
+
+// ParseMargins parses one to four lengths in CSS order, separated by
+// spaces or commas: all sides; top and bottom, then inside and outside;
+// top, inside and outside, then bottom; or top, outside, bottom and inside
+// (right, then left, on a right-hand page)
+func ParseMargins(s string) (Margins, error) {
+	var values []float64
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		// "12 mm": a unit on its own belongs to the number before it
+		if i+1 < len(fields) && unitRegex.MatchString(fields[i+1]) {
+			field += fields[i+1]
+			i++
+		}
+		value, err := ParseLength(field)
+		if err != nil {
+			return Margins{}, err
+		}
+		values = append(values, value)
+	}
+
+	switch len(values) {
+	case 1:
+		return UniformMargins(values[0]), nil
+	case 2:
+		return Margins{Top: values[0], Bottom: values[0], Inside: values[1], Outside: values[1]}, nil
+	case 3:
+		return Margins{Top: values[0], Bottom: values[2], Inside: values[1], Outside: values[1]}, nil
+	case 4:
+		return Margins{Top: values[0], Outside: values[1], Bottom: values[2], Inside: values[3]}, nil
+	}
+	return Margins{}, fmt.Errorf("invalid margins %q (use one to four lengths)", s)
+}
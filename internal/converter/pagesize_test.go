@@ -0,0 +1,134 @@
+package converter
+
+import (
+	"math"
+	"testing"
+)
+
+// near reports whether two lengths in inches agree to within a thousandth
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestParseLength(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"0.5", 0.5, true},
+		{"0.5in", 0.5, true},
+		{".75 IN", 0.75, true},
+		{"25.4mm", 1, true},
+		{"2.54cm", 1, true},
+		{"36pt", 0.5, true},
+		{"", 0, false},
+		{"1px", 0, false},
+		{"-1in", 0, false},
+		{"1.2.3", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseLength(tt.in)
+		if (err == nil) != tt.ok || !near(got, tt.want) {
+			t.Errorf("ParseLength(%q) = %v, %v, want %v (ok %t)", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want PageSize
+		ok   bool
+	}{
+		{"A4", PageSize{8.27, 11.69}, true},
+		{"letter", PageSize{8.5, 11}, true},
+		{" pocket ", PageSize{4.25, 6.87}, true},
+		{"6x9", PageSize{6, 9}, true},
+		{"5.5x8.5", PageSize{5.5, 8.5}, true},
+		{"7x10", PageSize{7, 10}, true},
+		{"6x9in", PageSize{6, 9}, true},
+		{"148x210mm", PageSize{5.827, 8.268}, true},
+		{"15cm x 23cm", PageSize{5.906, 9.055}, true},
+		{"15 × 23 cm", PageSize{5.906, 9.055}, true},
+		{"432X648pt", PageSize{6, 9}, true},
+		{"6inx210mm", PageSize{6, 8.268}, true},
+		{"A7", PageSize{}, false},
+		{"6x", PageSize{}, false},
+		{"6x9x12", PageSize{}, false},
+		{"0x9", PageSize{}, false},
+		{"6x9px", PageSize{}, false},
+		{"", PageSize{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParsePageSize(tt.in)
+		if (err == nil) != tt.ok || !near(got.Width, tt.want.Width) || !near(got.Height, tt.want.Height) {
+			t.Errorf("ParsePageSize(%q) = %v, %v, want %v (ok %t)", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseMargins(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Margins
+		ok   bool
+	}{
+		{"0.5", UniformMargins(0.5), true},
+		{"12.7mm", UniformMargins(0.5), true},
+		{"1in 0.5in", Margins{Top: 1, Bottom: 1, Inside: 0.5, Outside: 0.5}, true},
+		{"1, 0.5", Margins{Top: 1, Bottom: 1, Inside: 0.5, Outside: 0.5}, true},
+		{"1 0.5 2", Margins{Top: 1, Bottom: 2, Inside: 0.5, Outside: 0.5}, true},
+		{"1 2 3 4", Margins{Top: 1, Outside: 2, Bottom: 3, Inside: 4}, true},
+		{"72pt 2.54cm 25.4 mm 1in", UniformMargins(1), true},
+		{"", Margins{}, false},
+		{"1 2 3 4 5", Margins{}, false},
+		{"1 wide", Margins{}, false},
+		{"mm", Margins{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseMargins(tt.in)
+		if (err == nil) != tt.ok || !near(got.Top, tt.want.Top) || !near(got.Bottom, tt.want.Bottom) ||
+			!near(got.Inside, tt.want.Inside) || !near(got.Outside, tt.want.Outside) {
+			t.Errorf("ParseMargins(%q) = %+v, %v, want %+v (ok %t)", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPageLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		size    PageSize
+		margins Margins
+		ok      bool
+	}{
+		{"portrait", Options{PageSize: "6x9", Margins: UniformMargins(0.5)},
+			PageSize{6, 9}, UniformMargins(0.5), true},
+		{"landscape swaps the sides", Options{PageSize: "148x210mm", Landscape: true, Margins: UniformMargins(0.5)},
+			PageSize{8.268, 5.827}, UniformMargins(0.5), true},
+		{"landscape keeps the margins", Options{PageSize: "Letter", Landscape: true, Margins: Margins{Top: 1, Bottom: 2, Inside: 0.75, Outside: 0.25}},
+			PageSize{11, 8.5}, Margins{Top: 1, Bottom: 2, Inside: 0.75, Outside: 0.25}, true},
+		{"footer grows the margin", Options{PageSize: "A5", Footer: "|{page}|"},
+			PageSize{5.83, 8.27}, Margins{Bottom: 0.4}, true},
+		{"negative margin", Options{PageSize: "A4", Margins: Margins{Top: -1}}, PageSize{}, Margins{}, false},
+		{"margins fill the page", Options{PageSize: "6x9", Margins: Margins{Inside: 3, Outside: 3}}, PageSize{}, Margins{}, false},
+		{"landscape margins fill the page", Options{PageSize: "6x9", Landscape: true, Margins: Margins{Top: 3, Bottom: 3}}, PageSize{}, Margins{}, false},
+		{"bad size", Options{PageSize: "huge"}, PageSize{}, Margins{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, margins, err := tt.opts.pageLayout()
+			if (err == nil) != tt.ok {
+				t.Fatalf("pageLayout() error = %v, want ok %t", err, tt.ok)
+			}
+			if !near(size.Width, tt.size.Width) || !near(size.Height, tt.size.Height) {
+				t.Errorf("size = %v, want %v", size, tt.size)
+			}
+			if !near(margins.Top, tt.margins.Top) || !near(margins.Bottom, tt.margins.Bottom) ||
+				!near(margins.Inside, tt.margins.Inside) || !near(margins.Outside, tt.margins.Outside) {
+				t.Errorf("margins = %+v, want %+v", margins, tt.margins)
+			}
+		})
+	}
+}
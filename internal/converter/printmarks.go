@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/vib795/epub2pdf/internal/pdf"
+)
+
+// cropMarkSlug is the paper in inches kept outside the bleed for crop marks
+const cropMarkSlug = 0.25
+
+// cropMarkGap is the distance in inches between the bleed and a crop mark,
+// so the marks never show on the trimmed page
+const cropMarkGap = 1.0 / 16
+
+// slug returns the paper around the bleed area in inches
+func (o Options) slug() float64 {
+	if o.CropMarks {
+		return cropMarkSlug
+	}
+	return 0
+}
+
+// addPrintMarks sets the bleed and trim boxes of every page and draws crop
+// marks at the trim corners. Pages that aren't on the book's paper, such as
+// fixed-layout pages in viewport mode, are left alone.
+func addPrintMarks(doc *pdf.Document, paper PageSize, bleed, slug float64, cropMarks bool) error {
+	pages, err := doc.Pages()
+	if err != nil {
+		return err
+	}
+
+	bleedPt, slugPt := bleed*72, slug*72
+	for _, page := range pages {
+		media, err := doc.MediaBox(page)
+		if err != nil {
+			return err
+		}
+		if math.Abs(media.Width()-paper.Width*72) > 1 || math.Abs(media.Height()-paper.Height*72) > 1 {
+			continue
+		}
+
+		bleedBox := media.Inset(slugPt)
+		trimBox := bleedBox.Inset(bleedPt)
+		if err := doc.SetPageBoxes(page, map[pdf.Name]pdf.Rect{
+			"BleedBox": bleedBox,
+			"TrimBox":  trimBox,
+		}); err != nil {
+			return err
+		}
+
+		if cropMarks {
+			if err := doc.AppendContent(page, cropMarkContent(trimBox, bleedPt+cropMarkGap*72, slugPt-cropMarkGap*72)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cropMarkContent draws a horizontal and a vertical mark at each corner of
+// the trim box, starting offset points outside it and length points long
+func cropMarkContent(trim pdf.Rect, offset, length float64) []byte {
+	var sb strings.Builder
+	sb.WriteString("q 0.25 w 0 G [] 0 d\n")
+	line := func(x1, y1, x2, y2 float64) {
+		sb.WriteString(fmt.Sprintf("%.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2))
+	}
+	// Each corner's marks point away from the page
+	corners := []struct{ x, y, dx, dy float64 }{
+		{trim[0], trim[1], -1, -1},
+		{trim[2], trim[1], 1, -1},
+		{trim[0], trim[3], -1, 1},
+		{trim[2], trim[3], 1, 1},
+	}
+	for _, c := range corners {
+		line(c.x+c.dx*offset, c.y, c.x+c.dx*(offset+length), c.y)
+		line(c.x, c.y+c.dy*offset, c.x, c.y+c.dy*(offset+length))
+	}
+	sb.WriteString("Q\n")
+	return []byte(sb.String())
+}
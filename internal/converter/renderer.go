@@ -0,0 +1,26 @@
+package converter
+
+import "context"
+
+// Renderer prints an HTML document to PDF. Browser, driving headless
+// Chrome, is the built-in implementation.
+type Renderer interface {
+	// PrintToPDF loads the document at url, along with the resources it
+	// references, and prints it. It must stop when ctx is done.
+	PrintToPDF(ctx context.Context, url string, params PrintParams) ([]byte, error)
+}
+
+// PrintParams describe how to print a document. Lengths are in inches.
+type PrintParams struct {
+	PaperWidth   float64
+	PaperHeight  float64
+	MarginTop    float64
+	MarginBottom float64
+	MarginLeft   float64 // Right-hand pages' inside margin; @page :left mirrors it
+	MarginRight  float64
+
+	PrintBackground   bool
+	Scale             float64
+	PreferCSSPageSize bool   // Let the document's @page rules size the paper
+	PageRanges        string // Pages to keep, e.g. "1-5, 8"; empty for all
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/vib795/epub2pdf/internal/epub"
+)
+
+// bookServer serves the merged document and the book's resources to Chrome
+// over loopback HTTP, so resources stream from the archive on demand
+type bookServer struct {
+	server *http.Server
+	URL    string // Address of the merged document
+
+	mu       sync.RWMutex
+	document string
+	failed   []string // Resources that couldn't be read, as warnings
+}
+
+// serveBook starts a bookServer on a random loopback port. Everything is
+// served under a random path so other local processes can't guess it.
+func serveBook(book *epub.Book, document string) (*bookServer, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	root := "/" + hex.EncodeToString(token) + "/"
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &bookServer{
+		URL:      fmt.Sprintf("http://%s%s", listener.Addr(), root),
+		document: document,
+	}
+	s.server = &http.Server{Handler: http.StripPrefix(root[:len(root)-1], bookHandler(book, s.Document, s.resourceFailed))}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// Document returns the merged document being served
+func (s *bookServer) Document() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.document
+}
+
+// SetDocument replaces the merged document, e.g. for a second render pass
+func (s *bookServer) SetDocument(document string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.document = document
+}
+
+// resourceFailed records a resource of the book that couldn't be read.
+// Each is reported once, however often the browser asks for it.
+func (s *bookServer) resourceFailed(name string, err error) {
+	warning := fmt.Sprintf("could not read %s: %v", name, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, w := range s.failed {
+		if w == warning {
+			return
+		}
+	}
+	s.failed = append(s.failed, warning)
+}
+
+// Warnings returns the resources that couldn't be read so far
+func (s *bookServer) Warnings() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.failed...)
+}
+
+func (s *bookServer) Close() error {
+	return s.server.Close()
+}
+
+// bookHandler serves the merged document at "/" and the archive's files
+// under "/" + epub.ResourcePrefix, passing unreadable files to failed
+func bookHandler(book *epub.Book, document func() string, failed func(name string, err error)) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/"+epub.ResourcePrefix, http.StripPrefix("/"+epub.ResourcePrefix, book.ResourceHandler(failed)))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, document())
+	})
+	return mux
+}
@@ -0,0 +1,343 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vib795/epub2pdf/internal/epub"
+	"github.com/vib795/epub2pdf/internal/pdf"
+)
+
+// Split modes
+const (
+	SplitChapter  = "chapter"   // One PDF per chapter
+	SplitTOCLevel = "toc-level" // One PDF per TOC entry at a given depth
+	SplitPages    = "pages"     // One PDF per N pages
+)
+
+// Split describes how to split a book into several PDFs
+type Split struct {
+	Mode string // SplitChapter, SplitTOCLevel or SplitPages
+	N    int    // TOC depth (1 is the top level) or pages per PDF
+}
+
+var splitRegex = regexp.MustCompile(`^(?:(chapter)|toc-level-(\d+)|pages:(\d+))$`)
+
+// ParseSplit parses a split mode: chapter, toc-level-N or pages:N
+func ParseSplit(s string) (Split, error) {
+	m := splitRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return Split{}, fmt.Errorf("invalid split mode: %s (valid: chapter, toc-level-N, pages:N)", s)
+	}
+
+	var split Split
+	switch {
+	case m[1] != "":
+		split.Mode = SplitChapter
+	case m[2] != "":
+		split.Mode = SplitTOCLevel
+		split.N, _ = strconv.Atoi(m[2])
+	default:
+		split.Mode = SplitPages
+		split.N, _ = strconv.Atoi(m[3])
+	}
+	if split.Mode != SplitChapter && split.N < 1 {
+		return Split{}, fmt.Errorf("invalid split mode: %s (N must be at least 1)", s)
+	}
+	return split, nil
+}
+
+// check rejects the options that don't go with the split
+func (s Split) check(opts Options) error {
+	if s.Mode == SplitPages && opts.PageRanges != "" {
+		return fmt.Errorf("page ranges can't be combined with splitting by pages")
+	}
+	if s.Mode != SplitPages && opts.TOCPage {
+		return fmt.Errorf("a printed table of contents needs the whole book; split by pages instead")
+	}
+	return nil
+}
+
+// section is a run of chapters that becomes one PDF
+type section struct {
+	label    string
+	from, to int // Chapter indexes, to excluded
+}
+
+// Part is one PDF of a split book
+type Part struct {
+	Number, Total int
+	Label         string // Chapter or TOC label, or "pages 11-20"
+	Name          string // File name such as "03-chapter-one.pdf", sorting in order
+	Data          []byte
+}
+
+// ConvertSplit converts the book into a numbered series of PDFs in
+// outputDir, named after their TOC labels, and returns their paths and
+// the warnings of their conversions. The first PDF keeps the title page;
+// each carries the book's metadata with its section in the title.
+func ConvertSplit(ctx context.Context, book *epub.Book, outputDir string, split Split, opts Options) ([]string, []string, error) {
+	if err := split.check(opts); err != nil {
+		return nil, nil, err
+	}
+	browser, err := NewBrowser(ctx, opts.Chrome)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer browser.Close()
+	opts.progress("Using Chrome at %s", browser.Path())
+	return browser.writeSplit(ctx, book, outputDir, split, opts)
+}
+
+// ConvertSplit converts the book into a numbered series of PDFs in tabs of
+// the browser, like the package's ConvertSplit
+func (b *Browser) ConvertSplit(ctx context.Context, book *epub.Book, outputDir string, split Split, opts Options) ([]string, []string, error) {
+	if err := split.check(opts); err != nil {
+		return nil, nil, err
+	}
+	return b.writeSplit(ctx, book, outputDir, split, opts)
+}
+
+// writeSplit writes the parts of the book to outputDir
+func (b *Browser) writeSplit(ctx context.Context, book *epub.Book, outputDir string, split Split, opts Options) ([]string, []string, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	var paths []string
+	warnings, err := renderSplit(ctx, b, book, split, opts, func(part Part) error {
+		path := filepath.Join(outputDir, part.Name)
+		opts.progress("Writing part %d of %d: %s", part.Number, part.Total, path)
+		if err := os.WriteFile(path, part.Data, 0644); err != nil {
+			return fmt.Errorf("failed to write PDF: %w", err)
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, warnings, err
+}
+
+// RenderSplit renders the book as a numbered series of PDFs, passing each
+// to emit as soon as it is done, and returns the warnings of their
+// conversions. Each chapter or TOC section is rendered from its own
+// chapters only; runs of pages are cut from a single render of the whole
+// book. Warnings name the part they come from. An error from emit stops
+// the conversion and is returned.
+func RenderSplit(ctx context.Context, renderer Renderer, book *epub.Book, split Split, opts Options, emit func(Part) error) ([]string, error) {
+	if err := split.check(opts); err != nil {
+		return nil, err
+	}
+	return renderSplit(ctx, renderer, book, split, opts, emit)
+}
+
+func renderSplit(ctx context.Context, renderer Renderer, book *epub.Book, split Split, opts Options, emit func(Part) error) ([]string, error) {
+	if split.Mode == SplitPages {
+		return renderPages(ctx, renderer, book, split.N, opts, emit)
+	}
+
+	var sections []section
+	if split.Mode == SplitChapter {
+		for i, chapter := range book.Chapters {
+			sections = append(sections, section{label: chapter.Title, from: i, to: i + 1})
+		}
+	} else {
+		sections = tocSections(book, split.N)
+	}
+
+	// The book's own warnings are reported once rather than by every part
+	warnings := append([]string(nil), book.Warnings...)
+	for i, s := range sections {
+		part := book.Part(s.from, s.to)
+		part.Title = partTitle(book.Title, s.label)
+		part.Warnings = nil
+
+		partOpts := opts
+		if i > 0 {
+			partOpts.TitlePage = epub.TitlePageNone
+		}
+
+		opts.progress("Rendering part %d of %d: %s", i+1, len(sections), s.label)
+		data, result, err := Render(ctx, renderer, part, partOpts)
+		warnings = append(warnings, partWarnings(i+1, s.label, result.Warnings)...)
+		if err != nil {
+			return warnings, fmt.Errorf("part %d (%s): %w", i+1, s.label, err)
+		}
+		if err := emit(Part{
+			Number: i + 1,
+			Total:  len(sections),
+			Label:  s.label,
+			Name:   partFileName(i+1, len(sections), s.label),
+			Data:   data,
+		}); err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+// tocSections splits the chapters at the TOC entries of the given depth.
+// Shallower entries without children start sections of their own, and
+// chapters before the first section join it.
+func tocSections(book *epub.Book, depth int) []section {
+	index := make(map[string]int)
+	for i, chapter := range book.Chapters {
+		if _, ok := index[chapter.Path]; !ok {
+			index[chapter.Path] = i
+		}
+	}
+
+	type start struct {
+		label string
+		at    int
+	}
+	var starts []start
+	// A parent's chapter opens its first child's section, under the
+	// parent's label when they start together
+	pending, pendingLabel := -1, ""
+	var walk func(entries []epub.TOCEntry, level int)
+	walk = func(entries []epub.TOCEntry, level int) {
+		for _, entry := range entries {
+			at, ok := index[entry.Href]
+			if level < depth && len(entry.Children) > 0 {
+				if ok && pending == -1 {
+					pending, pendingLabel = at, entry.Label
+				}
+				walk(entry.Children, level+1)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			label := entry.Label
+			if pending != -1 {
+				if pending == at {
+					label = pendingLabel
+				}
+				at = min(at, pending)
+				pending = -1
+			}
+			if len(starts) == 0 || at > starts[len(starts)-1].at {
+				starts = append(starts, start{label, at})
+			}
+		}
+	}
+	walk(book.TOC, 1)
+
+	if len(starts) == 0 {
+		return []section{{label: book.Title, from: 0, to: len(book.Chapters)}}
+	}
+	starts[0].at = 0
+
+	sections := make([]section, len(starts))
+	for i, s := range starts {
+		end := len(book.Chapters)
+		if i+1 < len(starts) {
+			end = starts[i+1].at
+		}
+		sections[i] = section{label: s.label, from: s.at, to: end}
+	}
+	return sections
+}
+
+// renderPages renders the whole book once, then cuts the PDF into runs of
+// pages, each with the bookmarks and destinations of its own pages
+func renderPages(ctx context.Context, renderer Renderer, book *epub.Book, perPart int, opts Options, emit func(Part) error) ([]string, error) {
+	data, result, err := Render(ctx, renderer, book, opts)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := pdf.Open(data)
+	var pages []pdf.Ref
+	if err == nil {
+		pages, err = doc.Pages()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the rendered PDF: %w", err)
+	}
+	warnings := result.Warnings
+
+	count := (len(pages) + perPart - 1) / perPart
+	for i := 0; i < count; i++ {
+		first, last := i*perPart+1, min((i+1)*perPart, len(pages))
+		label := fmt.Sprintf("pages %d-%d", first, last)
+
+		partData := data // Nothing to split: keep the full render
+		if count > 1 {
+			part := book.Part(0, len(book.Chapters))
+			part.Title = partTitle(book.Title, label)
+			if partData, err = doc.Extract(pages[first-1 : last]); err != nil {
+				return warnings, fmt.Errorf("part %d (%s): %w", i+1, label, err)
+			}
+			if finished, err := finishPart(partData, part, opts); err != nil {
+				warnings = append(warnings, fmt.Sprintf("part %d (%s): could not post-process PDF: %v", i+1, label, err))
+			} else {
+				partData = finished
+			}
+		}
+		if err := emit(Part{
+			Number: i + 1,
+			Total:  count,
+			Label:  label,
+			Name:   partFileName(i+1, count, label),
+			Data:   partData,
+		}); err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+// finishPart adds the bookmarks and metadata of a part cut from the PDF
+// of the whole book. Its pages already carry their print marks.
+func finishPart(data []byte, part *epub.Book, opts Options) ([]byte, error) {
+	doc, err := pdf.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Bookmarks {
+		if err := addBookmarks(doc, part); err != nil {
+			return nil, fmt.Errorf("bookmarks: %w", err)
+		}
+	}
+	if err := setMetadata(doc, part); err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	return doc.Bytes(), nil
+}
+
+// partWarnings prefixes the warnings of a part with its number and label
+func partWarnings(number int, label string, warnings []string) []string {
+	var out []string
+	for _, warning := range warnings {
+		out = append(out, fmt.Sprintf("part %d (%s): %s", number, label, warning))
+	}
+	return out
+}
+
+// partTitle is the title of a part's PDF
+func partTitle(bookTitle, label string) string {
+	if label == "" || label == bookTitle {
+		return bookTitle
+	}
+	return bookTitle + " - " + label
+}
+
+var nonSlugRegex = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// partFileName returns a numbered file name such as "03-chapter-one.pdf",
+// padded so the files sort in order
+func partFileName(number, total int, label string) string {
+	slug := strings.Trim(nonSlugRegex.ReplaceAllString(strings.ToLower(label), "-"), "-")
+	if runes := []rune(slug); len(runes) > 60 {
+		slug = strings.TrimRight(string(runes[:60]), "-")
+	}
+	if slug == "" {
+		slug = "part"
+	}
+	width := max(2, len(strconv.Itoa(total)))
+	return fmt.Sprintf("%0*d-%s.pdf", width, number, slug)
+}
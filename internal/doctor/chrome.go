@@ -0,0 +1,180 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+	"github.com/vib795/epub2pdf/internal/pdf"
+)
+
+// FindChrome checks that the Chrome opts choose exists and returns its
+// path, or the DevTools URL of a remote Chrome
+func FindChrome(opts converter.ChromeOptions) (string, Check) {
+	check := Check{Name: "Chrome"}
+	if opts.Remote != "" {
+		check.Detail = "remote, at " + opts.Remote
+		return opts.Remote, check
+	}
+
+	path, err := converter.FindChrome(opts.Path)
+	if err != nil {
+		check.Status, check.Detail = Failed, err.Error()
+		check.Fix = "Install Chrome, Chromium or chrome-headless-shell, or point --chrome-path or " +
+			converter.ChromeEnv + " at the executable"
+		return "", check
+	}
+	check.Detail = path
+	return path, check
+}
+
+// StartChrome starts the browser, or connects to it, and reports its
+// version. A browser that takes longer than timeout is given up on, as a
+// hung start is what most "it hangs" reports come down to. The browser is
+// nil on failure; close it when done.
+func StartChrome(ctx context.Context, opts converter.ChromeOptions, timeout time.Duration) (*converter.Browser, Check) {
+	check := Check{Name: "Chrome start"}
+
+	// The browser lives as long as its context, so time the start out by
+	// canceling it rather than with a deadline
+	browserCtx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(timeout, cancel)
+	browser, err := converter.NewBrowser(browserCtx, opts)
+	if err != nil {
+		timedOut := !timer.Stop()
+		cancel()
+		msg := err.Error()
+		if opts.Remote == "" && !timedOut {
+			if path, err := converter.FindChrome(opts.Path); err == nil {
+				if out := probeChrome(ctx, path); out != "" {
+					msg = out
+				}
+			}
+		}
+		check.Status, check.Detail = Failed, strings.TrimSuffix(strings.Join(strings.Fields(msg), " "), ":")
+		if timedOut {
+			check.Detail = fmt.Sprintf("no response within %s", timeout)
+		}
+		check.Fix = startFix(msg, opts, timedOut)
+		return nil, check
+	}
+	timer.Stop()
+
+	versionCtx, cancelVersion := context.WithTimeout(ctx, timeout)
+	defer cancelVersion()
+	version, err := browser.Version(versionCtx)
+	if err != nil {
+		check.Status, check.Detail = Warning, fmt.Sprintf("started, but the version is unknown: %v", err)
+		return browser, check
+	}
+	check.Detail = version
+	if major := converter.MajorVersion(version); major > 0 && major < converter.MinHeaderVersion {
+		check.Status = Warning
+		check.Fix = fmt.Sprintf("Headers and footers need Chrome %d or later: update Chrome or install chrome-headless-shell", converter.MinHeaderVersion)
+	}
+	return browser, check
+}
+
+// probeChrome runs Chrome at path with --version and returns its output
+// if that fails too. It tells why Chrome can't run at all, e.g. missing
+// libraries, which the failed start doesn't always capture.
+func probeChrome(ctx context.Context, path string) string {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return strings.TrimSpace(string(out))
+	}
+	return ""
+}
+
+// startFix suggests what to do about a browser that didn't start, going
+// by the error message or Chrome's output
+func startFix(msg string, opts converter.ChromeOptions, timedOut bool) string {
+	switch {
+	case opts.Remote != "":
+		return "Check the DevTools URL, and that Chrome listens on an address this machine can reach (--remote-debugging-address=0.0.0.0)"
+	case timedOut:
+		return "Chrome didn't come up. In containers add --chrome-flag no-sandbox; otherwise try chrome-headless-shell with --chrome-path"
+	case strings.Contains(msg, "error while loading shared libraries"):
+		return "Chrome's system libraries are missing: install chrome-headless-shell, which needs none, or your distribution's chromium package"
+	case strings.Contains(msg, "sandbox"):
+		return "Chrome's sandbox can't start here: add --chrome-flag no-sandbox, or allow unprivileged user namespaces"
+	case strings.Contains(msg, "DISPLAY"), strings.Contains(msg, "X server"):
+		return "Chrome isn't running headless: remove --chrome-flag headless=false"
+	}
+	return "Try chrome-headless-shell with --chrome-path, or --chrome-flag no-sandbox"
+}
+
+// testDocument has a line of text in each script Fonts checks
+const testDocument = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>epub2pdf doctor</title></head>
+<body>
+<h1>epub2pdf</h1>
+<p>The quick brown fox jumps over the lazy dog.</p>
+<p>敏捷的棕色狐狸跳过了懒狗。</p>
+<p>الثعلب البني السريع يقفز فوق الكلب الكسول.</p>
+<p>तेज़ भूरी लोमड़ी आलसी कुत्ते के ऊपर कूदती है।</p>
+</body></html>`
+
+// Render prints a one-page document with browser, served from the
+// loopback interface like a book is
+func Render(ctx context.Context, browser *converter.Browser, timeout time.Duration) Check {
+	check := Check{Name: "Test render"}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		check.Status, check.Detail = Failed, fmt.Sprintf("can't listen on the loopback interface: %v", err)
+		check.Fix = "Books are served to Chrome from 127.0.0.1: allow listening on the loopback interface"
+		return check
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, testDocument)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	a4, _ := converter.ParsePageSize("A4")
+	start := time.Now()
+	data, err := browser.PrintToPDF(ctx, fmt.Sprintf("http://%s/", listener.Addr()), converter.PrintParams{
+		PaperWidth:      a4.Width,
+		PaperHeight:     a4.Height,
+		MarginTop:       0.5,
+		MarginBottom:    0.5,
+		MarginLeft:      0.5,
+		MarginRight:     0.5,
+		PrintBackground: true,
+		Scale:           1,
+	})
+	if err != nil {
+		check.Status, check.Detail = Failed, err.Error()
+		check.Fix = "Chrome started but didn't print. Make sure no proxy or firewall intercepts connections to 127.0.0.1, or try chrome-headless-shell"
+		if errors.Is(err, context.DeadlineExceeded) {
+			check.Detail = fmt.Sprintf("no PDF within %s", timeout)
+		}
+		return check
+	}
+	elapsed := time.Since(start)
+
+	doc, err := pdf.Open(data)
+	if err == nil {
+		_, err = doc.Pages()
+	}
+	if err != nil {
+		check.Status, check.Detail = Warning, fmt.Sprintf("Chrome printed %d bytes that can't be read back: %v", len(data), err)
+		check.Fix = "Bookmarks, metadata and page numbers won't be added: update Chrome"
+		return check
+	}
+	check.Detail = fmt.Sprintf("printed a test page in %s", elapsed.Round(10*time.Millisecond))
+	return check
+}
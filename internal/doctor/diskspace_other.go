@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package doctor
+
+import "errors"
+
+// freeSpace isn't implemented on this platform
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
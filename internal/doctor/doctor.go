@@ -0,0 +1,21 @@
+// Package doctor diagnoses the environment conversions run in: the
+// browser, the temp directory, Chrome's sandbox and the fonts installed for
+// common scripts. Every problem found comes with a suggested fix.
+package doctor
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	OK Status = iota
+	Warning
+	Failed
+)
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name   string
+	Status Status
+	Detail string // What was found
+	Fix    string // What to do about a warning or failure
+}
@@ -0,0 +1,84 @@
+package doctor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// scripts are writing systems books commonly use, with the fontconfig
+// language standing for each and the Debian and Fedora packages with fonts
+// for it
+var scripts = []struct {
+	name, lang, debian, fedora string
+}{
+	{"Latin", "en", "fonts-dejavu-core", "dejavu-sans-fonts"},
+	{"Chinese", "zh-cn", "fonts-noto-cjk", "google-noto-sans-cjk-fonts"},
+	{"Japanese", "ja", "fonts-noto-cjk", "google-noto-sans-cjk-fonts"},
+	{"Korean", "ko", "fonts-noto-cjk", "google-noto-sans-cjk-fonts"},
+	{"Arabic", "ar", "fonts-noto-core", "google-noto-sans-arabic-fonts"},
+	{"Devanagari", "hi", "fonts-noto-core", "google-noto-sans-devanagari-fonts"},
+}
+
+// Fonts lists the installed font families covering each script, asking
+// fontconfig's fc-list. macOS and Windows lack fc-list, but their stock
+// fonts cover these scripts.
+func Fonts(ctx context.Context) []Check {
+	fcList, err := exec.LookPath("fc-list")
+	if err != nil {
+		check := Check{Name: "Fonts", Detail: "not checked, fc-list not found"}
+		if runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+			check.Status = Warning
+			check.Fix = "Install fontconfig, which Chrome finds fonts with (apt install fontconfig)"
+		}
+		return []Check{check}
+	}
+
+	var checks []Check
+	for _, script := range scripts {
+		check := Check{Name: script.name + " fonts"}
+		families, err := fontFamilies(ctx, fcList, script.lang)
+		switch {
+		case err != nil:
+			check.Status, check.Detail = Warning, fmt.Sprintf("fc-list failed: %v", err)
+		case len(families) == 0:
+			check.Status, check.Detail = Warning, "none"
+			check.Fix = fmt.Sprintf("%s text prints as empty boxes: install %s (Debian, Ubuntu) or %s (Fedora)",
+				script.name, script.debian, script.fedora)
+		case len(families) > 3:
+			check.Detail = fmt.Sprintf("%s and %d more", strings.Join(families[:3], ", "), len(families)-3)
+		default:
+			check.Detail = strings.Join(families, ", ")
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// fontFamilies returns the sorted families of the fonts supporting lang
+func fontFamilies(ctx context.Context, fcList, lang string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, fcList, ":lang="+lang, "family").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var families []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// Localized names follow the first one, separated by commas
+		family, _, _ := strings.Cut(scanner.Text(), ",")
+		family = strings.TrimSpace(family)
+		if family != "" && !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+	sort.Strings(families)
+	return families, nil
+}
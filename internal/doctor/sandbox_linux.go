@@ -0,0 +1,75 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+)
+
+// userNamespaceSettings are the kernel settings that keep Chrome from
+// creating its sandbox in a user namespace, with their blocking values
+var userNamespaceSettings = []struct {
+	file, blocked, reason string
+}{
+	{"/proc/sys/kernel/unprivileged_userns_clone", "0", "unprivileged user namespaces are disabled"},
+	{"/proc/sys/user/max_user_namespaces", "0", "user namespaces are disabled"},
+	{"/proc/sys/kernel/apparmor_restrict_unprivileged_userns", "1", "AppArmor restricts unprivileged user namespaces"},
+}
+
+// Sandbox checks whether Chrome at path can start its sandbox, or has it
+// turned off where it can't. As root Chrome refuses to run sandboxed, so
+// --no-sandbox is added unless a "no-sandbox=false" flag removes it.
+func Sandbox(opts converter.ChromeOptions, path string) Check {
+	check := Check{Name: "Sandbox"}
+	value, set := opts.Flag("no-sandbox")
+	off := set && value != false
+
+	if os.Geteuid() == 0 {
+		if set && !off {
+			check.Status, check.Detail = Failed, "running as root with --chrome-flag no-sandbox=false"
+			check.Fix = "Chrome won't start sandboxed as root: drop --chrome-flag no-sandbox=false, or run epub2pdf as a regular user"
+			return check
+		}
+		check.Detail = "off, as epub2pdf runs as root (--no-sandbox is added automatically)"
+		return check
+	}
+	if off {
+		check.Detail = "off (--chrome-flag no-sandbox)"
+		return check
+	}
+
+	if isContainer() {
+		check.Status, check.Detail = Warning, "on, in a container, where it usually can't start"
+		check.Fix = "If Chrome fails to start, add --chrome-flag no-sandbox"
+		return check
+	}
+	for _, setting := range userNamespaceSettings {
+		data, err := os.ReadFile(setting.file)
+		if err != nil || strings.TrimSpace(string(data)) != setting.blocked {
+			continue
+		}
+		// Without user namespaces Chrome falls back to its setuid helper
+		helper, err := os.Stat(filepath.Join(filepath.Dir(path), "chrome-sandbox"))
+		if err == nil && helper.Mode()&os.ModeSetuid != 0 {
+			break
+		}
+		check.Status, check.Detail = Warning, "on, but "+setting.reason+" and Chrome has no setuid chrome-sandbox helper"
+		check.Fix = "If Chrome fails to start, add --chrome-flag no-sandbox, or allow user namespaces (" + setting.file + ")"
+		return check
+	}
+	check.Detail = "on"
+	return check
+}
+
+// isContainer reports whether epub2pdf runs in a Docker or Podman
+// container
+func isContainer() bool {
+	for _, file := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(file); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package doctor
+
+import "github.com/vib795/epub2pdf/internal/converter"
+
+// Sandbox reports Chrome's sandbox, which needs no setup outside Linux
+func Sandbox(opts converter.ChromeOptions, path string) Check {
+	if value, set := opts.Flag("no-sandbox"); set && value != false {
+		return Check{Name: "Sandbox", Detail: "off (--chrome-flag no-sandbox)"}
+	}
+	return Check{Name: "Sandbox", Detail: "on"}
+}
@@ -0,0 +1,37 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+)
+
+// minFreeSpace is the free space below which TempDir warns. Chrome keeps
+// its profile there, and the server uploads and finished PDFs.
+const minFreeSpace = 500 << 20
+
+// TempDir checks that the temp directory is writable and has room
+func TempDir() Check {
+	dir := os.TempDir()
+	check := Check{Name: "Temp directory"}
+
+	f, err := os.CreateTemp(dir, "epub2pdf-doctor-*")
+	if err != nil {
+		check.Status, check.Detail = Failed, fmt.Sprintf("%s is not writable: %v", dir, err)
+		check.Fix = "Make it writable, or point TMPDIR (TMP on Windows) at a writable directory"
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	free, err := freeSpace(dir)
+	if err != nil {
+		check.Detail = fmt.Sprintf("%s is writable (free space unknown: %v)", dir, err)
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s is writable, %.1f GB free", dir, float64(free)/(1<<30))
+	if free < minFreeSpace {
+		check.Status = Warning
+		check.Fix = fmt.Sprintf("Free up space in %s, or point TMPDIR (TMP on Windows) at a larger disk", dir)
+	}
+	return check
+}
@@ -0,0 +1,98 @@
+package epub
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// archive gives access to the files of an EPUB container, transparently
+// undoing font obfuscation declared in META-INF/encryption.xml
+type archive struct {
+	files      map[string]*zip.File
+	obfuscated map[string]string // path -> obfuscation algorithm
+	idpfKey    []byte
+	adobeKey   []byte
+	closer     io.Closer // nil when the caller owns the underlying reader
+}
+
+func newArchive(r *zip.Reader, closer io.Closer) *archive {
+	files := make(map[string]*zip.File)
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+	return &archive{files: files, closer: closer}
+}
+
+// lookup finds a file referenced from a document in basePath
+func (a *archive) lookup(cleanSrc, basePath string) (string, bool) {
+	candidates := []string{
+		resolveRelativePath(basePath, cleanSrc),
+		cleanSrc,
+		normalizePath(resolveRelativePath(basePath, cleanSrc)),
+	}
+	for _, name := range candidates {
+		if _, ok := a.files[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// find resolves a URL reference (which may be percent-encoded and carry a
+// query or fragment, e.g. "font.woff?#iefix") to a file in the archive
+func (a *archive) find(src, basePath string) (string, bool) {
+	if idx := strings.IndexAny(src, "?#"); idx != -1 {
+		src = src[:idx]
+	}
+	if decoded, err := url.PathUnescape(src); err == nil {
+		src = decoded
+	}
+	return a.lookup(src, basePath)
+}
+
+// open streams a file, de-obfuscating fonts as needed
+func (a *archive) open(name string) (io.ReadCloser, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("file not found in epub: %s", name)
+	}
+
+	algorithm, obfuscated := a.obfuscated[name]
+	var key []byte
+	var length int
+	if obfuscated {
+		var err error
+		if key, length, err = a.obfuscationKey(algorithm); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	if !obfuscated {
+		return rc, nil
+	}
+	return &deobfuscator{ReadCloser: rc, key: key, length: length}, nil
+}
+
+// read returns the content of a file, de-obfuscating fonts as needed
+func (a *archive) read(name string) ([]byte, error) {
+	rc, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func (a *archive) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
@@ -0,0 +1,80 @@
+package epub
+
+import (
+	"archive/zip"
+	"path"
+	"strings"
+)
+
+// Guide represents the EPUB 2 <guide> element
+type Guide struct {
+	References []GuideReference `xml:"reference"`
+}
+
+type GuideReference struct {
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// findCover locates the cover image and the cover XHTML page. It checks the
+// EPUB 3 cover-image property, the EPUB 2 <meta name="cover"> and finally
+// the first image of the <guide> cover reference.
+func findCover(pkg *Package, basePath string, files map[string]*zip.File) (imagePath, pagePath string) {
+	for _, ref := range pkg.Guide.References {
+		if strings.EqualFold(ref.Type, "cover") {
+			pagePath, _ = resolveHref(basePath, ref.Href)
+			break
+		}
+	}
+
+	for _, item := range pkg.Manifest.Items {
+		if hasProperty(item.Properties, "cover-image") {
+			return resolvePath(basePath, item.Href), pagePath
+		}
+	}
+
+	for _, meta := range pkg.Metadata.Metas {
+		if meta.Name != "cover" || meta.Content == "" {
+			continue
+		}
+		for _, item := range pkg.Manifest.Items {
+			// Some books put the href rather than the id in content
+			if (item.ID == meta.Content || item.Href == meta.Content) && strings.HasPrefix(item.MediaType, "image/") {
+				return resolvePath(basePath, item.Href), pagePath
+			}
+		}
+	}
+
+	if f, ok := files[pagePath]; ok && pagePath != "" {
+		if content, err := readFileContent(f); err == nil {
+			if images := imageSources(content); len(images) > 0 {
+				imagePath, _ = resolveHref(path.Dir(pagePath), images[0])
+				return imagePath, pagePath
+			}
+		}
+	}
+
+	return "", pagePath
+}
+
+// isCoverDocument reports whether a spine document only exists to show the
+// cover: either the guide says so, or it has no text and shows the cover image
+func isCoverDocument(docPath, content, coverPage, coverImage string) bool {
+	if docPath == coverPage {
+		return true
+	}
+	if coverImage == "" {
+		return false
+	}
+
+	body := extractBodyContent(content)
+	if hasText(body) {
+		return false
+	}
+	for _, src := range imageSources(body) {
+		if target, _ := resolveHref(path.Dir(docPath), src); target == coverImage {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package epub
+
+import (
+	"strings"
+)
+
+// cssScope rewrites stylesheet selectors so that a chapter's styles only
+// apply to that chapter in the merged document
+type cssScope struct {
+	wrapper string              // Selector matching the chapter wrapper(s)
+	prefix  bool                // Prefix plain selectors with the wrapper
+	ids     func(string) string // Maps an element id to a selector in the merged document
+}
+
+// rewrite returns css with every style rule's selectors rewritten. Blocks of
+// conditional at-rules (@media, @supports, ...) are rewritten recursively;
+// other at-rules (@font-face, @page, @keyframes, ...) are kept verbatim.
+func (s cssScope) rewrite(css string) string {
+	var out strings.Builder
+	i := 0
+	for i < len(css) {
+		// Copy whitespace and comments
+		if c := css[i]; c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' {
+			out.WriteByte(c)
+			i++
+			continue
+		}
+		if strings.HasPrefix(css[i:], "/*") {
+			end := strings.Index(css[i+2:], "*/")
+			if end == -1 {
+				break
+			}
+			out.WriteString(css[i : i+end+4])
+			i += end + 4
+			continue
+		}
+		// HTML comment delimiters are allowed (and ignored) in stylesheets
+		if strings.HasPrefix(css[i:], "<!--") {
+			i += 4
+			continue
+		}
+		if strings.HasPrefix(css[i:], "-->") {
+			i += 3
+			continue
+		}
+
+		// Prelude runs to the block or, for statements like @import, to ';'
+		end := scanCSS(css, i, "{;")
+		if end >= len(css) {
+			out.WriteString(css[i:])
+			break
+		}
+		prelude := css[i:end]
+		if css[end] == ';' {
+			out.WriteString(css[i : end+1])
+			i = end + 1
+			continue
+		}
+
+		blockEnd := matchingBrace(css, end)
+		block := css[end+1 : blockEnd]
+		if strings.HasPrefix(prelude, "@") {
+			name := strings.ToLower(strings.TrimLeft(strings.Fields(prelude + " ")[0], "@"))
+			switch name {
+			case "media", "supports", "layer", "container", "document", "-moz-document":
+				out.WriteString(prelude + "{" + s.rewrite(block) + "}")
+			default:
+				out.WriteString(prelude + "{" + block + "}")
+			}
+		} else {
+			out.WriteString(s.selectors(prelude) + "{" + block + "}")
+		}
+		i = blockEnd + 1
+	}
+	return out.String()
+}
+
+// scanCSS returns the index of the first character in stops at nesting
+// level zero, skipping strings, comments and bracketed groups
+func scanCSS(css string, i int, stops string) int {
+	depth := 0
+	for i < len(css) {
+		c := css[i]
+		switch {
+		case c == '"' || c == '\'':
+			i = skipCSSString(css, i)
+			continue
+		case c == '\\':
+			i += 2
+			continue
+		case strings.HasPrefix(css[i:], "/*"):
+			if end := strings.Index(css[i+2:], "*/"); end != -1 {
+				i += end + 4
+				continue
+			}
+			return len(css)
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && strings.IndexByte(stops, c) != -1:
+			return i
+		}
+		i++
+	}
+	return len(css)
+}
+
+func skipCSSString(css string, i int) int {
+	quote := css[i]
+	for i++; i < len(css); i++ {
+		if css[i] == '\\' {
+			i++
+			continue
+		}
+		if css[i] == quote || css[i] == '\n' {
+			return i + 1
+		}
+	}
+	return len(css)
+}
+
+// matchingBrace returns the index of the '}' closing the block opened at open
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); {
+		i = scanCSS(css, i, "{}")
+		if i >= len(css) {
+			break
+		}
+		if css[i] == '{' {
+			depth++
+		} else if depth--; depth == 0 {
+			return i
+		}
+		i++
+	}
+	return len(css)
+}
+
+// selectors rewrites a comma separated selector list
+func (s cssScope) selectors(list string) string {
+	var parts []string
+	for start := 0; start <= len(list); {
+		end := scanCSS(list, start, ",")
+		parts = append(parts, s.selector(list[start:end]))
+		start = end + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// selector rewrites a single complex selector. The last html, :root or body
+// compound becomes the chapter wrapper; everything else is prefixed with it.
+func (s cssScope) selector(sel string) string {
+	lead := sel[:len(sel)-len(strings.TrimLeft(sel, " \t\r\n\f"))]
+	trail := sel[len(strings.TrimRight(sel, " \t\r\n\f")):]
+	sel = strings.TrimSpace(sel)
+	if sel == "" {
+		return lead + trail
+	}
+	sel = s.mapIDs(sel)
+
+	compounds := splitCompounds(sel)
+	root := -1
+	rest := ""
+	for i, c := range compounds {
+		lower := strings.ToLower(c.text)
+		for _, name := range []string{"html", "body", ":root"} {
+			if strings.HasPrefix(lower, name) && (len(c.text) == len(name) || !isIdentChar(c.text[len(name)])) {
+				root, rest = i, c.text[len(name):]
+			}
+		}
+	}
+
+	if root == -1 {
+		if s.prefix {
+			return lead + s.wrapper + " " + sel + trail
+		}
+		return lead + sel + trail
+	}
+
+	var sb strings.Builder
+	sb.WriteString(s.wrapper + rest)
+	for _, c := range compounds[root+1:] {
+		sb.WriteString(c.combinator + c.text)
+	}
+	return lead + sb.String() + trail
+}
+
+type compound struct {
+	combinator string // Combinator preceding this compound (" ", " > ", ...)
+	text       string
+}
+
+// splitCompounds splits a selector on its combinators
+func splitCompounds(sel string) []compound {
+	var list []compound
+	combinator := ""
+	start := 0
+	for i := 0; i <= len(sel); {
+		end := scanCSS(sel, i, " \t\n\r\f>+~")
+		if end > start {
+			list = append(list, compound{combinator: combinator, text: sel[start:end]})
+		}
+		if end >= len(sel) {
+			break
+		}
+
+		// Collect the combinator, normalizing whitespace around it
+		j := end
+		op := " "
+		for j < len(sel) && strings.IndexByte(" \t\n\r\f>+~", sel[j]) != -1 {
+			if sel[j] != ' ' && sel[j] != '\t' && sel[j] != '\n' && sel[j] != '\r' && sel[j] != '\f' {
+				op = " " + string(sel[j]) + " "
+			}
+			j++
+		}
+		combinator = op
+		start, i = j, j
+	}
+	return list
+}
+
+// mapIDs replaces #id selectors (outside strings and attribute selectors)
+func (s cssScope) mapIDs(sel string) string {
+	if s.ids == nil || !strings.Contains(sel, "#") {
+		return sel
+	}
+
+	var out strings.Builder
+	brackets := 0
+	for i := 0; i < len(sel); {
+		c := sel[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := skipCSSString(sel, i)
+			out.WriteString(sel[i:end])
+			i = end
+			continue
+		case c == '[':
+			brackets++
+		case c == ']' && brackets > 0:
+			brackets--
+		case c == '#' && brackets == 0:
+			j := i + 1
+			for j < len(sel) && isIdentChar(sel[j]) {
+				j++
+			}
+			if j > i+1 {
+				out.WriteString(s.ids(sel[i+1 : j]))
+				i = j
+				continue
+			}
+		}
+		out.WriteByte(c)
+		i++
+	}
+	return out.String()
+}
+
+func isIdentChar(c byte) bool {
+	return c == '-' || c == '_' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,80 @@
+package epub
+
+import "testing"
+
+func TestCSSScope(t *testing.T) {
+	scope := cssScope{wrapper: ".chapter", prefix: true, ids: func(id string) string {
+		return "#" + namespacedID(1, id)
+	}}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"type selector", "p{color:red}", ".chapter p{color:red}"},
+		{"whitespace kept", "p {color: red}\n", ".chapter p {color: red}\n"},
+		{"selector list", "h1,h2{x:1}", ".chapter h1,.chapter h2{x:1}"},
+		{"body becomes the wrapper", "body{margin:0}", ".chapter{margin:0}"},
+		{"html and :root too", "html{a:1}:root{b:2}", ".chapter{a:1}.chapter{b:2}"},
+		{"body with class", "body.dark p{x:1}", ".chapter.dark p{x:1}"},
+		{"last root compound wins", "html > body div{x:1}", ".chapter div{x:1}"},
+		{"names starting with body", "bodyguard{x:1}", ".chapter bodyguard{x:1}"},
+		{"combinators", "div>p+span~em{x:1}", ".chapter div>p+span~em{x:1}"},
+		{"ids mapped", "#intro p{x:1}", ".chapter #epub-ch1-intro p{x:1}"},
+		{"ids in attribute selectors kept", `a[href="#x"]{x:1}`, `.chapter a[href="#x"]{x:1}`},
+		{"commas in :is() kept", ":is(h1,h2) b{x:1}", ".chapter :is(h1,h2) b{x:1}"},
+		{"media blocks recursed", "@media print{p{x:1}}", "@media print{.chapter p{x:1}}"},
+		{"supports blocks recursed", "@supports (display:grid){body{x:1}}", "@supports (display:grid){.chapter{x:1}}"},
+		{"font-face verbatim", "@font-face{font-family:F;src:url(f.woff)}", "@font-face{font-family:F;src:url(f.woff)}"},
+		{"page verbatim", "@page{margin:1in}", "@page{margin:1in}"},
+		{"keyframes verbatim", "@keyframes k{from{a:1}to{a:2}}", "@keyframes k{from{a:1}to{a:2}}"},
+		{"statements kept", "@charset \"utf-8\";p{x:1}", "@charset \"utf-8\";.chapter p{x:1}"},
+		{"comments kept", "/* p{} */p{x:1}", "/* p{} */.chapter p{x:1}"},
+		{"braces in strings", `p{content:"}"}a{x:1}`, `.chapter p{content:"}"}.chapter a{x:1}`},
+		{"HTML comment delimiters dropped", "<!-- p{x:1} -->", " .chapter p{x:1} "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scope.rewrite(tt.in); got != tt.want {
+				t.Errorf("rewrite(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSSScopeWithoutPrefix(t *testing.T) {
+	// Book-wide stylesheets only retarget html and body
+	scope := cssScope{wrapper: ".chapter"}
+	tests := map[string]string{
+		"p{x:1}":      "p{x:1}",
+		"body p{x:1}": ".chapter p{x:1}",
+		"#a{x:1}":     "#a{x:1}",
+	}
+	for in, want := range tests {
+		if got := scope.rewrite(in); got != want {
+			t.Errorf("rewrite(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestScanCSS(t *testing.T) {
+	tests := []struct {
+		in    string
+		stops string
+		want  int
+	}{
+		{"a,b", ",", 1},
+		{`"a,b",c`, ",", 5},
+		{"f(a,b),c", ",", 6},
+		{"[a=','],c", ",", 7},
+		{"/* , */,", ",", 7},
+		{`a\,b,c`, ",", 4},
+		{"abc", ",", 3},
+		{"/* unterminated ,", ",", 17},
+	}
+	for _, tt := range tests {
+		if got := scanCSS(tt.in, 0, tt.stops); got != tt.want {
+			t.Errorf("scanCSS(%q, %q) = %d, want %d", tt.in, tt.stops, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package epub
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Fixed-layout rendering modes
+const (
+	FixedLayoutFit      = "fit"      // Scale each page to fit the paper size
+	FixedLayoutViewport = "viewport" // Use each page's viewport as its paper size
+)
+
+// Viewport is the size in CSS pixels a fixed-layout document is designed for
+type Viewport struct {
+	Width  float64
+	Height float64
+}
+
+func (v Viewport) valid() bool {
+	return v.Width > 0 && v.Height > 0
+}
+
+// packageLayout reports whether the package is pre-paginated and returns
+// its default viewport, from EPUB 3 rendition metadata or the older
+// fixed-layout / original-resolution metas
+func packageLayout(meta Metadata) (fixed bool, viewport Viewport) {
+	for _, m := range meta.Metas {
+		value := strings.TrimSpace(m.Value)
+		switch {
+		case m.Refines != "":
+		case m.Property == "rendition:layout":
+			fixed = value == "pre-paginated"
+		case m.Property == "rendition:viewport":
+			viewport = parseViewportContent(value)
+		case m.Name == "fixed-layout":
+			fixed = fixed || strings.EqualFold(m.Content, "true")
+		case m.Name == "original-resolution" && !viewport.valid():
+			if w, h, ok := strings.Cut(strings.ToLower(m.Content), "x"); ok {
+				viewport.Width, _ = strconv.ParseFloat(strings.TrimSpace(w), 64)
+				viewport.Height, _ = strconv.ParseFloat(strings.TrimSpace(h), 64)
+			}
+		}
+	}
+	return fixed, viewport
+}
+
+// itemLayout applies an itemref's rendition:layout override
+func itemLayout(properties string, fixed bool) bool {
+	switch {
+	case hasProperty(properties, "rendition:layout-pre-paginated"):
+		return true
+	case hasProperty(properties, "rendition:layout-reflowable"):
+		return false
+	}
+	return fixed
+}
+
+// parseViewport returns the viewport of a fixed-layout document: its
+// <meta name="viewport">, or else the size of an SVG document's root
+func parseViewport(content string) Viewport {
+	z := newTokenizer(content)
+	inBody := false
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return Viewport{}
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		attrs := attributes(tok)
+		switch tok.Data {
+		case "body":
+			inBody = true
+		case "meta":
+			if !inBody && strings.EqualFold(attrs["name"], "viewport") {
+				if v := parseViewportContent(attrs["content"]); v.valid() {
+					return v
+				}
+			}
+		case "svg":
+			return svgViewport(attrs)
+		}
+	}
+}
+
+// svgViewport returns the size of an <svg> element from its viewBox, or
+// else its width and height
+func svgViewport(attrs map[string]string) Viewport {
+	if box := strings.FieldsFunc(attrs["viewbox"], func(r rune) bool { return r == ' ' || r == ',' }); len(box) == 4 {
+		w, _ := strconv.ParseFloat(box[2], 64)
+		h, _ := strconv.ParseFloat(box[3], 64)
+		if v := (Viewport{w, h}); v.valid() {
+			return v
+		}
+	}
+	w, _ := strconv.ParseFloat(strings.TrimSuffix(attrs["width"], "px"), 64)
+	h, _ := strconv.ParseFloat(strings.TrimSuffix(attrs["height"], "px"), 64)
+	return Viewport{w, h}
+}
+
+// parseViewportContent parses "width=1200, height=1600"
+func parseViewportContent(content string) Viewport {
+	var v Viewport
+	for _, part := range strings.FieldsFunc(content, func(r rune) bool { return r == ',' || r == ';' }) {
+		key, value, _ := strings.Cut(part, "=")
+		n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "width":
+			v.Width = n
+		case "height":
+			v.Height = n
+		}
+	}
+	return v
+}
+
+// fixedPageName returns the name of the @page rule a fixed-layout page uses
+func fixedPageName(v Viewport, opts HTMLOptions) string {
+	if opts.FixedLayout == FixedLayoutViewport && v.valid() {
+		return fmt.Sprintf("epub2pdf-fixed-%dx%d", int(v.Width), int(v.Height))
+	}
+	return "epub2pdf-fixed"
+}
+
+// writeFixedCSS writes the page rules for the book's fixed-layout documents.
+// In viewport mode each distinct viewport gets a page of its own size.
+func writeFixedCSS(sb *strings.Builder, chapters []Chapter, opts HTMLOptions) {
+	rules := make(map[string]string)
+	for _, chapter := range chapters {
+		if !chapter.Fixed {
+			continue
+		}
+		name := fixedPageName(chapter.Viewport, opts)
+		switch {
+		case name != "epub2pdf-fixed":
+			rules[name] = fmt.Sprintf("size: %gpx %gpx; margin: 0;", chapter.Viewport.Width, chapter.Viewport.Height)
+		case opts.Slug > 0:
+			// Full-bleed pages stop at the edge of the bleed area
+			rules[name] = fmt.Sprintf("margin: %.3fin;", opts.Slug)
+		default:
+			rules[name] = "margin: 0;"
+		}
+	}
+
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("@page %s { %s }\n", name, rules[name]))
+	}
+}
+
+// writeFixedPage writes a fixed-layout document as exactly one page. The
+// document is laid out at its viewport size and scaled to fit the page.
+func writeFixedPage(sb *strings.Builder, chapter Chapter, opts HTMLOptions) {
+	v := chapter.Viewport
+	pageWidth, pageHeight := opts.bleedSize()
+	pageWidth, pageHeight = pageWidth*96, pageHeight*96
+	scale := 1.0
+
+	switch {
+	case opts.FixedLayout == FixedLayoutViewport && v.valid(), pageWidth <= 0 || pageHeight <= 0:
+		// The page is the viewport itself
+		if !v.valid() {
+			v = Viewport{816, 1056}
+		}
+		pageWidth, pageHeight = v.Width, v.Height
+	case !v.valid():
+		// Without a viewport the document is laid out on the page as is
+		v = Viewport{pageWidth, pageHeight}
+	default:
+		scale = min(pageWidth/v.Width, pageHeight/v.Height)
+	}
+
+	sb.WriteString(fmt.Sprintf("<div class=\"fixed-page\" style=\"page: %s; width: %gpx; height: %gpx;\">\n",
+		fixedPageName(chapter.Viewport, opts), pageWidth, pageHeight))
+	sb.WriteString(fmt.Sprintf("<div class=\"fixed-frame\" style=\"width: %.2fpx; height: %.2fpx;\">\n", v.Width*scale, v.Height*scale))
+	writeChapter(sb, chapter, fmt.Sprintf("width: %gpx; height: %gpx; transform: scale(%.5f);", v.Width, v.Height, scale))
+	sb.WriteString("</div>\n</div>\n")
+}
@@ -0,0 +1,126 @@
+package epub
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Font obfuscation algorithms (not DRM: the key is the book's identifier)
+const (
+	AlgorithmIDPF  = "http://www.idpf.org/2008/embedding"
+	AlgorithmAdobe = "http://ns.adobe.com/pdf/enc#RC"
+)
+
+// Encryption represents the META-INF/encryption.xml structure
+type Encryption struct {
+	XMLName xml.Name `xml:"encryption"`
+	Data    []struct {
+		Method struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"EncryptionMethod"`
+		Reference struct {
+			URI string `xml:"URI,attr"`
+		} `xml:"CipherData>CipherReference"`
+	} `xml:"EncryptedData"`
+}
+
+var uuidRegex = regexp.MustCompile(`(?i)[0-9a-f]{8}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{12}`)
+
+// loadEncryption reads META-INF/encryption.xml and derives the
+// de-obfuscation keys from the package identifiers. Fonts are read as they
+// are stored if it fails.
+func (a *archive) loadEncryption(meta BookMetadata) error {
+	f, ok := a.files["META-INF/encryption.xml"]
+	if !ok {
+		return nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var enc Encryption
+	if err := xml.NewDecoder(rc).Decode(&enc); err != nil {
+		return fmt.Errorf("failed to parse encryption.xml: %w", err)
+	}
+
+	a.obfuscated = make(map[string]string)
+	for _, data := range enc.Data {
+		name := data.Reference.URI
+		if decoded, err := url.PathUnescape(name); err == nil {
+			name = decoded
+		}
+		a.obfuscated[normalizePath(name)] = data.Method.Algorithm
+	}
+
+	// IDPF: SHA-1 of the unique identifier with whitespace removed
+	uid := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, meta.UniqueIdentifier)
+	sum := sha1.Sum([]byte(uid))
+	a.idpfKey = sum[:]
+
+	// Adobe: the raw bytes of the book's UUID
+	candidates := []string{meta.UniqueIdentifier}
+	for _, id := range meta.Identifiers {
+		candidates = append(candidates, id.Value)
+	}
+	for _, c := range candidates {
+		if m := uuidRegex.FindString(c); m != "" {
+			a.adobeKey, _ = hex.DecodeString(strings.ReplaceAll(m, "-", ""))
+			break
+		}
+	}
+
+	return nil
+}
+
+// obfuscationKey returns the key of an obfuscation algorithm and the
+// number of leading bytes it applies to
+func (a *archive) obfuscationKey(algorithm string) ([]byte, int, error) {
+	var key []byte
+	var length int
+
+	switch algorithm {
+	case AlgorithmIDPF:
+		key, length = a.idpfKey, 1040
+	case AlgorithmAdobe:
+		key, length = a.adobeKey, 1024
+	default:
+		return nil, 0, fmt.Errorf("unsupported encryption algorithm %s", algorithm)
+	}
+	if len(key) == 0 {
+		return nil, 0, fmt.Errorf("no key for obfuscation algorithm %s", algorithm)
+	}
+	return key, length, nil
+}
+
+// deobfuscator reverses font obfuscation while reading. Obfuscation XORs
+// the start of the file with the key, so applying it again restores the font.
+type deobfuscator struct {
+	io.ReadCloser
+	key    []byte
+	length int
+	pos    int
+}
+
+func (d *deobfuscator) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	for i := 0; i < n && d.pos+i < d.length; i++ {
+		p[i] ^= d.key[(d.pos+i)%len(d.key)]
+	}
+	d.pos += n
+	return n, err
+}
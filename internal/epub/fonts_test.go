@@ -0,0 +1,118 @@
+package epub
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// obfuscate XORs the first length bytes of font with key
+func obfuscate(font, key []byte, length int) []byte {
+	out := bytes.Clone(font)
+	for i := 0; i < length && i < len(out); i++ {
+		out[i] ^= key[i%len(key)]
+	}
+	return out
+}
+
+const testEncryption = `<?xml version="1.0"?>
+<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/>
+    <enc:CipherData><enc:CipherReference URI="OEBPS/fonts/idpf%20font.otf"/></enc:CipherData>
+  </enc:EncryptedData>
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://ns.adobe.com/pdf/enc#RC"/>
+    <enc:CipherData><enc:CipherReference URI="OEBPS/fonts/adobe.otf"/></enc:CipherData>
+  </enc:EncryptedData>
+</encryption>`
+
+func TestDeobfuscateFonts(t *testing.T) {
+	font := make([]byte, 2000)
+	for i := range font {
+		font[i] = byte(i * 7)
+	}
+
+	uuid := "urn:uuid:12345678-9abc-def0-1234-56789abcdef0"
+	meta := BookMetadata{
+		UniqueIdentifier: " urn:uuid:12345678-9abc-def0-\n1234-56789abcdef0 ",
+		Identifiers:      []Identifier{{Value: uuid, Scheme: "UUID"}},
+	}
+	idpfKey := sha1.Sum([]byte(uuid))
+	adobeKey, _ := hex.DecodeString("123456789abcdef0123456789abcdef0")
+
+	a := testArchive(t, map[string]string{
+		"META-INF/encryption.xml":   testEncryption,
+		"OEBPS/fonts/idpf font.otf": string(obfuscate(font, idpfKey[:], 1040)),
+		"OEBPS/fonts/adobe.otf":     string(obfuscate(font, adobeKey, 1024)),
+		"OEBPS/fonts/plain.otf":     string(font),
+	})
+	if err := a.loadEncryption(meta); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"OEBPS/fonts/idpf font.otf", "OEBPS/fonts/adobe.otf", "OEBPS/fonts/plain.otf"} {
+		got, err := a.read(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, font) {
+			t.Errorf("%s: read back differs from the font", name)
+		}
+
+		// Reads of any size line up with the key
+		rc, err := a.open(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err = io.ReadAll(iotest.OneByteReader(rc))
+		rc.Close()
+		if err != nil || !bytes.Equal(got, font) {
+			t.Errorf("%s: byte-by-byte read differs from the font (%v)", name, err)
+		}
+	}
+}
+
+func TestMalformedEncryption(t *testing.T) {
+	chapter := `<html><head><title>One</title></head><body><p>Text</p></body></html>`
+	font := "not really a font"
+	a := testArchive(t, map[string]string{
+		"META-INF/container.xml": `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`,
+		"META-INF/encryption.xml": `<encryption><EncryptedData>`,
+		"OEBPS/content.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="id">urn:uuid:12345678-9abc-def0-1234-56789abcdef0</dc:identifier>
+    <dc:title>Book</dc:title>
+  </metadata>
+  <manifest>
+    <item id="one" href="one.xhtml" media-type="application/xhtml+xml"/>
+    <item id="font" href="font.otf" media-type="font/otf"/>
+  </manifest>
+  <spine><itemref idref="one"/></spine>
+</package>`,
+		"OEBPS/one.xhtml": chapter,
+		"OEBPS/font.otf":  font,
+	})
+
+	book, err := parseArchive(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Warnings) != 1 || !strings.Contains(book.Warnings[0], "encryption.xml") {
+		t.Errorf("warnings = %q, want one about encryption.xml", book.Warnings)
+	}
+	if len(book.Chapters) != 1 {
+		t.Errorf("%d chapters, want 1", len(book.Chapters))
+	}
+	if got, err := a.read("OEBPS/font.otf"); err != nil || string(got) != font {
+		t.Errorf("font = %q, %v, want it as stored", got, err)
+	}
+}
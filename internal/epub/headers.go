@@ -0,0 +1,207 @@
+package epub
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Header and footer templates are up to three "|" separated parts, placed
+// at the left, center and right of the page margin. Parts may contain
+// {page}, {pages}, {title}, {author} and {chapter}.
+
+// HeaderFooterPresets are ready-made header and footer templates
+var HeaderFooterPresets = map[string]struct{ Header, Footer string }{
+	"none":    {},
+	"page":    {Footer: "|{page}|"},
+	"book":    {Header: "{title}||{chapter}", Footer: "|{page}|"},
+	"review":  {Header: "{author}|{title}|{chapter}", Footer: "|Page {page} of {pages}|"},
+	"chapter": {Header: "|{chapter}|", Footer: "|{page} / {pages}|"},
+}
+
+// PresetNames returns the names of the header and footer presets
+func PresetNames() []string {
+	names := make([]string, 0, len(HeaderFooterPresets))
+	for name := range HeaderFooterPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var placeholderRegex = regexp.MustCompile(`\{(page|pages|title|author|chapter)\}`)
+
+// Headers and footers are set in headerFontSize points, headerLineHeight
+// times that per line, with headerGap inches between them and the content
+const (
+	headerFontSize   = 9.0
+	headerLineHeight = 1.2
+	headerGap        = 0.25
+)
+
+// HeaderFooterHeight returns the margin in inches a header or footer
+// template needs: its tallest part's lines and the gap to the content. An
+// empty template needs none.
+func HeaderFooterHeight(template string) float64 {
+	if template == "" {
+		return 0
+	}
+	lines := 1
+	for _, part := range templateParts(template) {
+		lines = max(lines, strings.Count(part, "\n")+1)
+	}
+	return float64(lines)*headerFontSize*headerLineHeight/72 + headerGap
+}
+
+// marginBoxes are the page-margin boxes of a header or footer, left to right
+var marginBoxes = map[string][3]string{
+	"top":    {"@top-left", "@top-center", "@top-right"},
+	"bottom": {"@bottom-left", "@bottom-center", "@bottom-right"},
+}
+
+// templateParts splits a template into its left, center and right parts.
+// A template without "|" is centered.
+func templateParts(template string) [3]string {
+	parts := strings.Split(template, "|")
+	switch len(parts) {
+	case 1:
+		return [3]string{"", parts[0], ""}
+	case 2:
+		return [3]string{parts[0], "", parts[1]}
+	}
+	return [3]string{parts[0], parts[1], strings.Join(parts[2:], "|")}
+}
+
+// cssContent converts a template part to a CSS content value
+func (b *Book) cssContent(part, chapter string) string {
+	var values []string
+	literal := func(s string) {
+		if s != "" {
+			values = append(values, cssString(s))
+		}
+	}
+
+	last := 0
+	for _, m := range placeholderRegex.FindAllStringSubmatchIndex(part, -1) {
+		literal(part[last:m[0]])
+		switch part[m[2]:m[3]] {
+		case "page":
+			values = append(values, "counter(page)")
+		case "pages":
+			values = append(values, "counter(pages)")
+		case "title":
+			literal(b.Title)
+		case "author":
+			literal(b.Author)
+		case "chapter":
+			literal(chapter)
+		}
+		last = m[1]
+	}
+	literal(part[last:])
+
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, " ")
+}
+
+// cssString quotes a string for use in CSS
+func cssString(s string) string {
+	return `"` + cssQuote(s) + `"`
+}
+
+// runningTitles returns the running chapter title of every chapter: its TOC
+// label, or that of the closest preceding chapter in the TOC
+func (b *Book) runningTitles() map[int]string {
+	labels := chapterTitles(b.TOC)
+	titles := make(map[int]string)
+	current := ""
+	for _, chapter := range b.Chapters {
+		if label, ok := labels[chapter.Path]; ok {
+			current = label
+		}
+		titles[chapter.Order] = current
+	}
+	return titles
+}
+
+// writeHeaderCSS writes page-margin boxes for the header and footer. Running
+// chapter titles need a named page per chapter. Cover, title, fixed-layout
+// and blank pages have no header or footer.
+func (b *Book) writeHeaderCSS(sb *strings.Builder, chapters []Chapter, opts HTMLOptions) {
+	if opts.Header == "" && opts.Footer == "" {
+		return
+	}
+
+	templates := map[string]string{"top": opts.Header, "bottom": opts.Footer}
+
+	// Keep the header and footer off the bleed and slug
+	padding := map[string]string{}
+	if offset := opts.trimOffset(); offset > 0 {
+		padding["top"] = fmt.Sprintf(" padding-top: %.3fin;", offset)
+		padding["bottom"] = fmt.Sprintf(" padding-bottom: %.3fin;", offset)
+	}
+	writeBoxes := func(page string, chapter string, onlyRunning bool) {
+		var rules []string
+		for _, side := range []string{"top", "bottom"} {
+			if templates[side] == "" {
+				continue
+			}
+			for i, part := range templateParts(templates[side]) {
+				if part == "" || onlyRunning && !strings.Contains(part, "{chapter}") {
+					continue
+				}
+				rules = append(rules, fmt.Sprintf("%s { content: %s; font-family: sans-serif; font-size: %gpt; line-height: %g; white-space: pre-line; color: #555;%s }",
+					marginBoxes[side][i], b.cssContent(part, chapter), headerFontSize, headerLineHeight, padding[side]))
+			}
+		}
+		if len(rules) > 0 {
+			sb.WriteString(fmt.Sprintf("@page%s {\n\t%s\n}\n", page, strings.Join(rules, "\n\t")))
+		}
+	}
+	writeBoxes("", "", false)
+
+	// Each chapter gets a named page carrying its title
+	if strings.Contains(opts.Header+opts.Footer, "{chapter}") {
+		titles := b.runningTitles()
+		for _, chapter := range chapters {
+			if chapter.Fixed || titles[chapter.Order] == "" {
+				continue
+			}
+			name := fmt.Sprintf("epub2pdf-%s", ChapterAnchor(chapter.Order))
+			sb.WriteString(fmt.Sprintf("#%s { page: %s; }\n", ChapterAnchor(chapter.Order), name))
+			writeBoxes(" "+name, titles[chapter.Order], true)
+		}
+	}
+
+	// Pages without header or footer, including the blank left-hand pages
+	// before chapters in print mode
+	plain := map[string]bool{"epub2pdf-cover": true, "epub2pdf-title": true}
+	if opts.Print {
+		plain[":blank"] = true
+	}
+	for _, chapter := range chapters {
+		if chapter.Fixed {
+			plain[fixedPageName(chapter.Viewport, opts)] = true
+		}
+	}
+	var none []string
+	for _, side := range []string{"top", "bottom"} {
+		if templates[side] != "" {
+			for _, box := range marginBoxes[side] {
+				none = append(none, box+" { content: none; }")
+			}
+		}
+	}
+	sb.WriteString(".title-page { page: epub2pdf-title; }\n")
+	names := make([]string, 0, len(plain))
+	for name := range plain {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("@page %s { %s }\n", name, strings.Join(none, " ")))
+	}
+}
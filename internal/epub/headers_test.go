@@ -0,0 +1,89 @@
+package epub
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTemplateParts(t *testing.T) {
+	tests := map[string][3]string{
+		"{page}":             {"", "{page}", ""},
+		"{title}|{page}":     {"{title}", "", "{page}"},
+		"{title}||{page}":    {"{title}", "", "{page}"},
+		"a|b|c":              {"a", "b", "c"},
+		"a|b|c|d":            {"a", "b", "c|d"},
+		"|Page {page} of |":  {"", "Page {page} of ", ""},
+		"|{chapter}|":        {"", "{chapter}", ""},
+		"{author}|{title}|x": {"{author}", "{title}", "x"},
+	}
+	for template, want := range tests {
+		if got := templateParts(template); got != want {
+			t.Errorf("templateParts(%q) = %q, want %q", template, got, want)
+		}
+	}
+}
+
+func TestHeaderFooterHeight(t *testing.T) {
+	line := headerFontSize * headerLineHeight / 72
+	tests := []struct {
+		template string
+		want     float64
+	}{
+		{"", 0},
+		{"|{page}|", line + headerGap},
+		{"{title}||{chapter}", line + headerGap},
+		{"{title}\n{author}||{page}", 2*line + headerGap},
+		{"a||b\nc\nd", 3*line + headerGap},
+	}
+	for _, tt := range tests {
+		if got := HeaderFooterHeight(tt.template); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("HeaderFooterHeight(%q) = %v, want %v", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestCSSContent(t *testing.T) {
+	b := &Book{Title: `The "Book"`, Author: "A. Writer"}
+	tests := []struct {
+		part, chapter, want string
+	}{
+		{"{page}", "", "counter(page)"},
+		{"Page {page} of {pages}", "", `"Page " counter(page) " of " counter(pages)`},
+		{"{title}", "", `"The \"Book\""`},
+		{"{author}: {chapter}", "One", `"A. Writer" ": " "One"`},
+		{"{chapter}", "", "none"},
+		{"{unknown}", "", `"{unknown}"`},
+	}
+	for _, tt := range tests {
+		if got := b.cssContent(tt.part, tt.chapter); got != tt.want {
+			t.Errorf("cssContent(%q) = %s, want %s", tt.part, got, tt.want)
+		}
+	}
+}
+
+func TestWriteHeaderCSS(t *testing.T) {
+	b := &Book{
+		Title: "Book",
+		TOC:   []TOCEntry{{Label: "One", Href: "one.xhtml"}},
+		Chapters: []Chapter{
+			{Path: "one.xhtml", Order: 1},
+			{Path: "one-b.xhtml", Order: 2},
+		},
+	}
+	var sb strings.Builder
+	b.writeHeaderCSS(&sb, b.Chapters, HTMLOptions{Header: "{title}||{chapter}", Footer: "|{page}|"})
+	css := sb.String()
+	for _, want := range []string{
+		`@top-left { content: "Book";`,
+		`@bottom-center { content: counter(page);`,
+		// The running title carries over to chapters missing from the TOC
+		`page: epub2pdf-` + ChapterAnchor(2),
+		`@page epub2pdf-` + ChapterAnchor(2) + ` {` + "\n\t" + `@top-right { content: "One";`,
+		`@page epub2pdf-cover { @top-left { content: none; }`,
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("header CSS lacks %q:\n%s", want, css)
+		}
+	}
+}
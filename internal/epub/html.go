@@ -0,0 +1,436 @@
+package epub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Title page modes
+const (
+	TitlePageCover     = "cover"     // The book's own cover image, falling back to a generated page
+	TitlePageGenerated = "generated" // A generated page with the title and author
+	TitlePageNone      = "none"      // No title page
+)
+
+// HTMLOptions controls how the book is merged into a single HTML document
+type HTMLOptions struct {
+	TitlePage   string  // TitlePageCover, TitlePageGenerated or TitlePageNone
+	FixedLayout string  // FixedLayoutFit or FixedLayoutViewport
+	Header      string  // Header template ("left|center|right"), empty for none
+	Footer      string  // Footer template
+	TOCPage     bool    // Print a table of contents after the title page
+	PageWidth   float64 // Page width in inches, used to size full-bleed pages
+	PageHeight  float64 // Page height in inches
+
+	// Inside and outside margins in inches. The paper's own left and right
+	// margins apply to right-hand pages; left-hand pages mirror them.
+	InsideMargin  float64
+	OutsideMargin float64
+
+	// Print-ready output: chapters start on right-hand pages. The page is
+	// the trimmed page, surrounded on the paper by Bleed inches of bleed and
+	// Slug inches of blank paper for crop marks.
+	Print bool
+	Bleed float64
+	Slug  float64
+
+	// PreferCSSPageSize lets the book's own @page rules set the page size
+	// and margins; the options' margins only fill in what they leave out
+	PreferCSSPageSize bool
+
+	// Typography: a theme from Themes, and overrides of its font family,
+	// font size (a CSS length) and line height
+	Theme      string
+	FontFamily string
+	FontSize   string
+	LineHeight float64
+
+	UserCSS   []string // Stylesheets applied after all others
+	NoBookCSS bool     // Drop the book's stylesheets, except for fixed-layout pages
+
+	// PageNumbers maps anchors to the page they landed on in a previous
+	// render, filling in the printed table of contents
+	PageNumbers map[string]int
+}
+
+// DefaultHTMLOptions returns sensible defaults
+func DefaultHTMLOptions() HTMLOptions {
+	return HTMLOptions{
+		TitlePage:   TitlePageCover,
+		FixedLayout: FixedLayoutFit,
+	}
+}
+
+// ToHTML converts the book to a single HTML document
+func (b *Book) ToHTML(opts HTMLOptions) string {
+	var sb strings.Builder
+
+	// A fixed-layout cover document is rendered as its own page instead
+	fixedCover := false
+	for _, chapter := range b.Chapters {
+		fixedCover = fixedCover || (chapter.IsCover && chapter.Fixed)
+	}
+	useCover := opts.TitlePage == TitlePageCover && b.coverSrc != "" && !fixedCover
+	generated := opts.TitlePage == TitlePageGenerated || (opts.TitlePage == TitlePageCover && !useCover && !fixedCover)
+
+	if len(b.Metadata.Languages) > 0 {
+		sb.WriteString(fmt.Sprintf("<!DOCTYPE html>\n<html lang=\"%s\">\n<head>\n", escapeHTML(b.Metadata.Languages[0])))
+	} else {
+		sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
+	}
+	sb.WriteString("<meta charset=\"UTF-8\">\n")
+	sb.WriteString(fmt.Sprintf("<title>%s</title>\n", escapeHTML(b.Title)))
+
+	// Embed CSS
+	sb.WriteString("<style>\n")
+	sb.WriteString(`
+		body {
+			font-family: Georgia, 'Times New Roman', serif;
+			line-height: 1.6;
+			margin: 0;
+			color: #333;
+		}
+		.book {
+			max-width: 800px;
+			margin: 0 auto;
+			padding: 40px 20px;
+		}
+		:where(.book) :is(h1, h2, h3, h4, h5, h6) {
+			margin-top: 1.5em;
+			margin-bottom: 0.5em;
+		}
+		:where(.book) p {
+			margin: 0.8em 0;
+			text-align: justify;
+		}
+		:where(.book) img {
+			max-width: 100%;
+			height: auto;
+		}
+		.chapter {
+			page-break-before: always;
+		}
+		.chapter:first-child {
+			page-break-before: avoid;
+		}
+		.title-page {
+			text-align: center;
+			padding: 100px 0;
+		}
+		.title-page h1 {
+			font-size: 2.5em;
+			margin-bottom: 0.5em;
+		}
+		.title-page .author {
+			font-size: 1.3em;
+			color: #666;
+		}
+		@page epub2pdf-cover {
+			margin: 0;
+		}
+		.cover-page {
+			page: epub2pdf-cover;
+			display: flex;
+			align-items: center;
+			justify-content: center;
+			overflow: hidden;
+			break-after: page;
+		}
+		.cover-page img {
+			width: 100%;
+			height: 100%;
+			max-width: none;
+			object-fit: contain;
+		}
+		.fixed-page {
+			display: flex;
+			align-items: center;
+			justify-content: center;
+			overflow: hidden;
+			break-before: page;
+		}
+		.fixed-frame {
+			position: relative;
+			flex: none;
+			overflow: hidden;
+		}
+		.fixed-layout {
+			position: absolute;
+			top: 0;
+			left: 0;
+			overflow: hidden;
+			transform-origin: 0 0;
+			line-height: normal;
+		}
+		.epub2pdf-anchors {
+			position: absolute;
+			top: 0;
+			left: 0;
+			width: 1px;
+			height: 1px;
+			overflow: hidden;
+			color: transparent;
+		}
+	`)
+	// Size the cover to the paper; viewport units are the fallback
+	if useCover {
+		width, height := "100vw", "100vh"
+		if opts.PageWidth > 0 && opts.PageHeight > 0 {
+			w, h := opts.bleedSize()
+			width = fmt.Sprintf("%.3fin", w)
+			height = fmt.Sprintf("%.3fin", h)
+		}
+		sb.WriteString(fmt.Sprintf(".cover-page { width: %s; height: %s; }\n", width, height))
+	}
+	var chapters []Chapter
+	for _, chapter := range b.Chapters {
+		// The cover page already shows the cover document's image
+		if !(useCover && chapter.IsCover) {
+			chapters = append(chapters, chapter)
+		}
+	}
+	if opts.TOCPage {
+		sb.WriteString(tocPageCSS)
+	}
+	writePageCSS(&sb, opts)
+	if opts.Print || opts.PreferCSSPageSize {
+		// The page margins already frame the text on paper
+		sb.WriteString(".book { max-width: none; margin: 0; padding: 0; }\n")
+	}
+	writeFixedCSS(&sb, chapters, opts)
+	b.writeHeaderCSS(&sb, chapters, opts)
+	if opts.NoBookCSS {
+		// Fixed-layout pages can't be laid out without their styles
+		var fixed []Chapter
+		for _, chapter := range chapters {
+			if chapter.Fixed {
+				fixed = append(fixed, chapter)
+			}
+		}
+		b.writeBookCSS(&sb, fixed, false)
+	} else {
+		b.writeBookCSS(&sb, chapters, true)
+	}
+	writeThemeCSS(&sb, opts)
+	writeUserCSS(&sb, opts)
+	sb.WriteString("</style>\n")
+	sb.WriteString("</head>\n<body>\n")
+
+	// Cover page
+	if useCover {
+		// Links to the skipped cover document land on the cover page instead
+		coverID := ""
+		for _, chapter := range b.Chapters {
+			if chapter.IsCover {
+				coverID = fmt.Sprintf(" id=\"%s\"", ChapterAnchor(chapter.Order))
+				break
+			}
+		}
+		sb.WriteString(fmt.Sprintf("<div class=\"cover-page\"%s>\n", coverID))
+		sb.WriteString(fmt.Sprintf("<img src=\"%s\" alt=\"%s\"/>\n", b.coverSrc, escapeHTML(b.Title)))
+		sb.WriteString("</div>\n")
+	}
+
+	// Reflowable chapters flow inside .book; fixed-layout pages stand alone
+	inBook := false
+	openBook := func() {
+		if !inBook {
+			sb.WriteString("<div class=\"book\">\n")
+			inBook = true
+		}
+	}
+
+	// Title page
+	if generated {
+		openBook()
+		sb.WriteString("<div class=\"title-page\">\n")
+		sb.WriteString(fmt.Sprintf("<h1>%s</h1>\n", escapeHTML(b.Title)))
+		if b.Author != "" {
+			sb.WriteString(fmt.Sprintf("<p class=\"author\">%s</p>\n", escapeHTML(b.Author)))
+		}
+		sb.WriteString("</div>\n")
+	}
+
+	// Printed table of contents
+	if opts.TOCPage && len(b.TOC) > 0 {
+		openBook()
+		b.writeTOCPage(&sb, opts)
+	}
+
+	// Chapters
+	for _, chapter := range chapters {
+		if chapter.Fixed {
+			if inBook {
+				sb.WriteString("</div>\n")
+				inBook = false
+			}
+			writeFixedPage(&sb, chapter, opts)
+			continue
+		}
+		openBook()
+		writeChapter(&sb, chapter, "")
+	}
+
+	if inBook {
+		sb.WriteString("</div>\n")
+	}
+
+	// Chrome only emits PDF named destinations for elements that are the
+	// target of a link, so link every TOC target from an invisible block
+	if anchors := b.tocAnchors(); len(anchors) > 0 {
+		sb.WriteString("<div class=\"epub2pdf-anchors\" aria-hidden=\"true\">\n")
+		for _, anchor := range anchors {
+			sb.WriteString(fmt.Sprintf("<a href=\"#%s\">&#8203;</a>\n", escapeHTML(anchor)))
+		}
+		sb.WriteString("</div>\n")
+	}
+
+	sb.WriteString("</body>\n</html>")
+
+	return sb.String()
+}
+
+// writeChapter writes a chapter's body inside its wrapper element
+func writeChapter(sb *strings.Builder, chapter Chapter, style string) {
+	sb.WriteString(chapterWrapper(chapter, style))
+	if chapter.Body.ID != "" {
+		sb.WriteString(fmt.Sprintf("<span id=\"%s\"></span>", escapeHTML(namespacedID(chapter.Order, chapter.Body.ID))))
+	}
+	// Extract body content if it's a full HTML document
+	sb.WriteString(extractBodyContent(chapter.Content))
+	sb.WriteString("\n</div>\n")
+}
+
+// chapterWrapper opens the element standing in for a chapter's <body>
+func chapterWrapper(chapter Chapter, style string) string {
+	class := "chapter"
+	if chapter.Fixed {
+		class += " fixed-layout"
+	}
+	if chapter.Body.Class != "" {
+		class += " " + chapter.Body.Class
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<div class=\"%s\" id=\"%s\"", escapeHTML(class), ChapterAnchor(chapter.Order)))
+	if chapter.Body.Dir != "" {
+		sb.WriteString(fmt.Sprintf(" dir=\"%s\"", escapeHTML(chapter.Body.Dir)))
+	}
+	if chapter.Body.Lang != "" {
+		sb.WriteString(fmt.Sprintf(" lang=\"%s\"", escapeHTML(chapter.Body.Lang)))
+	}
+	if chapter.Body.EpubType != "" {
+		sb.WriteString(fmt.Sprintf(" epub:type=\"%s\"", escapeHTML(chapter.Body.EpubType)))
+	}
+	if style != "" {
+		sb.WriteString(fmt.Sprintf(" style=\"%s\"", escapeHTML(style)))
+	}
+	sb.WriteString(">\n")
+	return sb.String()
+}
+
+// writeBookCSS writes the book's stylesheets for the given chapters. When
+// global, a stylesheet linked by every chapter applies to the whole
+// document; others are scoped to the chapters linking them, as are inline
+// <style> blocks. In all cases body selectors match the chapter wrappers.
+func (b *Book) writeBookCSS(sb *strings.Builder, chapters []Chapter, global bool) {
+	linked := make(map[string][]Chapter)
+	anyLinks := false
+	for _, chapter := range chapters {
+		for _, cssPath := range chapter.Stylesheets {
+			linked[cssPath] = append(linked[cssPath], chapter)
+			anyLinks = true
+		}
+	}
+
+	for _, sheet := range b.CSS {
+		users := linked[sheet.Path]
+		switch {
+		case len(chapters) == 0:
+			continue
+		case !anyLinks || len(users) == len(chapters):
+			// Books that link no stylesheets at all get every stylesheet globally
+			sb.WriteString(scopeFor(chapters, !global).rewrite(sheet.Content))
+		case len(users) > 0:
+			sb.WriteString(scopeFor(users, true).rewrite(sheet.Content))
+		default:
+			continue
+		}
+		sb.WriteString("\n")
+	}
+
+	for _, chapter := range chapters {
+		for _, style := range chapter.Styles {
+			sb.WriteString(scopeFor([]Chapter{chapter}, true).rewrite(style))
+			sb.WriteString("\n")
+		}
+	}
+}
+
+// scopeFor builds the CSS scope for a set of chapters. Unprefixed scopes
+// apply to the whole document but still map body to the chapter wrappers.
+func scopeFor(chapters []Chapter, prefix bool) cssScope {
+	scope := cssScope{wrapper: ".chapter", prefix: prefix}
+	if prefix {
+		scope.wrapper = isSelector(chapters, func(c Chapter) string { return ChapterAnchor(c.Order) })
+	}
+
+	// Element ids are namespaced per chapter, so #id selectors must be too
+	scope.ids = func(id string) string {
+		var owners []Chapter
+		for _, c := range chapters {
+			if c.ids[id] {
+				owners = append(owners, c)
+			}
+		}
+		if len(owners) == 0 {
+			return "#" + id
+		}
+		return isSelector(owners, func(c Chapter) string { return namespacedID(c.Order, id) })
+	}
+	return scope
+}
+
+// isSelector returns "#a" or ":is(#a,#b)" for the given chapters
+func isSelector(chapters []Chapter, id func(Chapter) string) string {
+	ids := make([]string, len(chapters))
+	for i, c := range chapters {
+		ids[i] = "#" + cssEscape(id(c))
+	}
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	return ":is(" + strings.Join(ids, ",") + ")"
+}
+
+// cssEscape escapes characters that aren't valid in a CSS identifier
+func cssEscape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isIdentChar(c) && !(i == 0 && c >= '0' && c <= '9') {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteString(fmt.Sprintf("\\%x ", c))
+		}
+	}
+	return sb.String()
+}
+
+// BodyAttributes are the attributes of a chapter's <body> that are carried
+// over to its wrapper in the merged document
+type BodyAttributes struct {
+	ID       string
+	Class    string
+	Dir      string
+	Lang     string
+	EpubType string
+}
+
+func escapeHTML(s string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = strings.ReplaceAll(s, "<", "&lt;")
+	s = strings.ReplaceAll(s, ">", "&gt;")
+	s = strings.ReplaceAll(s, "\"", "&quot;")
+	return s
+}
@@ -0,0 +1,62 @@
+package epub
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var schemeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// ChapterAnchor returns the id of the element wrapping a chapter in the
+// merged HTML document
+func ChapterAnchor(order int) string {
+	return fmt.Sprintf("epub-ch%d", order)
+}
+
+// namespacedID prefixes an element id with its chapter anchor so that ids
+// repeated across chapters (every chapter having id="p1") stay unique
+func namespacedID(order int, id string) string {
+	return ChapterAnchor(order) + "-" + id
+}
+
+// Anchor returns the id in the merged HTML document that a link target
+// (document path and fragment) resolves to, or "" when the target document
+// is not part of the book
+func (b *Book) Anchor(href, fragment string) string {
+	for _, chapter := range b.Chapters {
+		if chapter.Path != href {
+			continue
+		}
+		if fragment != "" {
+			return namespacedID(chapter.Order, fragment)
+		}
+		return ChapterAnchor(chapter.Order)
+	}
+	return ""
+}
+
+// linkTarget maps an href found in a chapter to "#anchor" in the merged
+// document. Links to files that aren't chapters, which would be dead in the
+// PDF, map to "" for the link to be dropped. ok is false for hrefs kept as
+// they are, such as web links.
+func (b *Book) linkTarget(baseDir string, order int, href string) (target string, ok bool) {
+	href = strings.TrimSpace(href)
+	if href == "" || schemeRegex.MatchString(href) || strings.HasPrefix(href, "//") {
+		return "", false
+	}
+
+	if strings.HasPrefix(href, "#") {
+		if href == "#" {
+			return "", false
+		}
+		_, fragment := resolveHref(baseDir, href)
+		return "#" + namespacedID(order, fragment), true
+	}
+
+	doc, fragment := resolveHref(baseDir, href)
+	if anchor := b.Anchor(doc, fragment); anchor != "" {
+		return "#" + anchor, true
+	}
+	return "", true
+}
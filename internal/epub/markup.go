@@ -0,0 +1,205 @@
+package epub
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// tokenizer walks a document's markup with an HTML tokenizer, tracking
+// where each token starts. Comments and CDATA sections are single tokens,
+// so markup inside them is never read as tags, and XHTML self-closing tags
+// such as "<title/>" are empty elements rather than the start of raw text.
+type tokenizer struct {
+	*html.Tokenizer
+	offset, end int // Byte offsets of the current token
+}
+
+func newTokenizer(content string) *tokenizer {
+	return &tokenizer{Tokenizer: html.NewTokenizer(strings.NewReader(content))}
+}
+
+// Next moves to the next token
+func (z *tokenizer) Next() html.TokenType {
+	tt := z.Tokenizer.Next()
+	z.offset = z.end
+	z.end += len(z.Raw())
+	if tt == html.SelfClosingTagToken {
+		z.NextIsNotRawText()
+	}
+	return tt
+}
+
+// attributes returns the attributes of a tag by lowercase name, unescaped
+func attributes(tok html.Token) map[string]string {
+	attrs := make(map[string]string, len(tok.Attr))
+	for _, attr := range tok.Attr {
+		key := attr.Key
+		if attr.Namespace != "" {
+			key = attr.Namespace + ":" + key
+		}
+		attrs[key] = attr.Val
+	}
+	return attrs
+}
+
+// parseChapterHead extracts the inline styles and linked stylesheet hrefs
+// from a chapter's <head>, and the attributes of its <body>
+func parseChapterHead(content string) (styles []string, links []string, body BodyAttributes) {
+	z := newTokenizer(content)
+	var style *strings.Builder // The <style> being read
+	media := ""
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return styles, links, body
+
+		case html.TextToken:
+			if style != nil {
+				style.Write(z.Raw())
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			attrs := attributes(tok)
+			switch tok.Data {
+			case "style":
+				style, media = &strings.Builder{}, attrs["media"]
+			case "link":
+				rel := strings.ToLower(attrs["rel"])
+				if hasProperty(rel, "stylesheet") && !hasProperty(rel, "alternate") && attrs["href"] != "" {
+					links = append(links, attrs["href"])
+				}
+			case "body":
+				body = BodyAttributes{
+					ID:       attrs["id"],
+					Class:    attrs["class"],
+					Dir:      attrs["dir"],
+					Lang:     firstNonEmpty(attrs["lang"], attrs["xml:lang"]),
+					EpubType: attrs["epub:type"],
+				}
+				return styles, links, body
+			}
+
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "style" && style != nil {
+				text := style.String()
+				if media != "" && media != "all" {
+					text = "@media " + media + " {\n" + text + "\n}"
+				}
+				styles = append(styles, text)
+				style = nil
+			}
+		}
+	}
+}
+
+// documentTitle returns the text of a document's <title>
+func documentTitle(content string) string {
+	z := newTokenizer(content)
+	var title *strings.Builder
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.TextToken:
+			if title != nil {
+				title.Write(z.Raw())
+			}
+		case html.StartTagToken:
+			switch name, _ := z.TagName(); string(name) {
+			case "title":
+				title = &strings.Builder{}
+			case "body":
+				return ""
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "title" && title != nil {
+				return cleanLabel(title.String())
+			}
+		}
+	}
+}
+
+// extractBodyContent returns the markup between a document's <body> tags,
+// or all of it when it has no body
+func extractBodyContent(content string) string {
+	z := newTokenizer(content)
+	start, end := -1, -1
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if name, _ := z.TagName(); string(name) == "body" && start == -1 {
+				start = z.end
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "body" {
+				end = z.offset
+			}
+		}
+	}
+	if start == -1 {
+		return content
+	}
+	if end < start {
+		end = len(content)
+	}
+	return content[start:end]
+}
+
+// imageSources returns the images shown by markup, in order: the src of
+// each <img> and the href of each SVG <image>
+func imageSources(markup string) []string {
+	z := newTokenizer(markup)
+	var sources []string
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return sources
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		attrs := attributes(tok)
+		var src string
+		switch tok.Data {
+		case "img":
+			src = attrs["src"]
+		case "image":
+			src = firstNonEmpty(attrs["href"], attrs["xlink:href"])
+		}
+		if src = strings.TrimSpace(src); src != "" {
+			sources = append(sources, src)
+		}
+	}
+}
+
+// hasText reports whether markup has any text outside of tags, comments,
+// scripts and styles
+func hasText(markup string) bool {
+	z := newTokenizer(markup)
+	hidden := 0 // Depth inside <script> and <style>
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return false
+		case html.TextToken:
+			if hidden == 0 && strings.TrimSpace(html.UnescapeString(string(z.Raw()))) != "" {
+				return true
+			}
+		case html.StartTagToken:
+			if name, _ := z.TagName(); string(name) == "script" || string(name) == "style" {
+				hidden++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); (string(name) == "script" || string(name) == "style") && hidden > 0 {
+				hidden--
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package epub
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChapterHead(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		styles []string
+		links  []string
+		body   BodyAttributes
+	}{
+		{"styles and links",
+			`<html><head><style>p { color: red }</style><style media="print">h1 { margin: 0 }</style>` +
+				`<link rel="stylesheet" href="a.css"/><link rel="alternate stylesheet" href="b.css"/><link rel="icon" href="c.png"/></head>` +
+				`<body id="b" class="c" xml:lang="fr" epub:type="bodymatter"><p>x</p></body></html>`,
+			[]string{"p { color: red }", "@media print {\nh1 { margin: 0 }\n}"},
+			[]string{"a.css"},
+			BodyAttributes{ID: "b", Class: "c", Lang: "fr", EpubType: "bodymatter"}},
+		{"uppercase and single quotes", `<HEAD><LINK REL='Stylesheet' HREF='a&amp;b.css'></HEAD><BODY DIR='rtl' LANG='ar'>`,
+			nil, []string{"a&b.css"}, BodyAttributes{Dir: "rtl", Lang: "ar"}},
+		{"commented out", `<head><!-- <link rel="stylesheet" href="old.css"> <style>p{}</style> --></head><body><!-- <body id="x"> -->`,
+			nil, nil, BodyAttributes{}},
+		{"style holding markup", `<head><style>/* <body id="no"> */ p::before { content: "<link>" }</style></head><body id="yes">`,
+			[]string{`/* <body id="no"> */ p::before { content: "<link>" }`}, nil, BodyAttributes{ID: "yes"}},
+		{"nothing after the body", `<body><style>p{}</style><link rel="stylesheet" href="late.css">`,
+			nil, nil, BodyAttributes{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			styles, links, body := parseChapterHead(tt.in)
+			if !reflect.DeepEqual(styles, tt.styles) {
+				t.Errorf("styles = %q, want %q", styles, tt.styles)
+			}
+			if !reflect.DeepEqual(links, tt.links) {
+				t.Errorf("links = %q, want %q", links, tt.links)
+			}
+			if body != tt.body {
+				t.Errorf("body = %+v, want %+v", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestDocumentTitle(t *testing.T) {
+	tests := map[string]string{
+		`<head><title>Chapter &amp; <i>Verse</i></title></head>`:              "Chapter & Verse",
+		`<head><!-- <title>Old</title> --><title> New </title></head>`:        "New",
+		`<head><title/><meta charset="utf-8"/></head><body><p>Text</p>`:       "",
+		`<head></head><body><svg><title>Figure</title></svg></body>`:          "",
+		`<svg xmlns="http://www.w3.org/2000/svg"><title>Page 1</title></svg>`: "Page 1",
+	}
+	for in, want := range tests {
+		if got := documentTitle(in); got != want {
+			t.Errorf("documentTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExtractBodyContent(t *testing.T) {
+	tests := map[string]string{
+		`<html><body class="x"><p>One</p></body></html>`: "<p>One</p>",
+		`<p>Fragment</p>`: "<p>Fragment</p>",
+		`<head><!-- <body> --></head><body><p>A</p><!-- </body> --></body>`: "<p>A</p><!-- </body> -->",
+		`<body><script>"</body>"</script><p>B</p></body>`:                   `<script>"</body>"</script><p>B</p>`,
+		`<body><p>Unclosed</p>`:                                             "<p>Unclosed</p>",
+	}
+	for in, want := range tests {
+		if got := extractBodyContent(in); got != want {
+			t.Errorf("extractBodyContent(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseViewport(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Viewport
+	}{
+		{`<head><meta name="viewport" content="width=1200, height=1600"/></head><body></body>`, Viewport{1200, 1600}},
+		{`<head><META NAME='Viewport' CONTENT='width=600px;height=800px'></head>`, Viewport{600, 800}},
+		{`<head><!-- <meta name="viewport" content="width=1, height=1"> --></head><body><svg viewBox="0 0 300 400"></svg></body>`, Viewport{300, 400}},
+		{`<svg xmlns="http://www.w3.org/2000/svg" width="640px" height="480px">`, Viewport{640, 480}},
+		{`<body><meta name="viewport" content="width=10, height=10"></body>`, Viewport{}},
+	}
+	for _, tt := range tests {
+		if got := parseViewport(tt.in); got != tt.want {
+			t.Errorf("parseViewport(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestImageSources(t *testing.T) {
+	in := `<!-- <img src="old.jpg"> --><![CDATA[<img src="cdata.jpg">]]><div><IMG SRC="cover.jpg" alt="Cover"/>` +
+		`<svg><image xlink:href="a.png"/><image href="b.png" width="10"/></svg>` +
+		`<script>document.write('<img src="x.jpg">')</script><img alt="no source"></div>`
+	want := []string{"cover.jpg", "a.png", "b.png"}
+	if got := imageSources(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("imageSources = %q, want %q", got, want)
+	}
+}
+
+func TestHasText(t *testing.T) {
+	tests := map[string]bool{
+		`<div><img src="cover.jpg"/></div>`:                  false,
+		"<p>\n&#160;</p>":                                    false,
+		`<!-- Cover --><style>p { color: red }</style><br/>`: false,
+		`<p>Chapter One</p>`:                                 true,
+		`<svg><text>Title</text></svg>`:                      true,
+	}
+	for in, want := range tests {
+		if got := hasText(in); got != want {
+			t.Errorf("hasText(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
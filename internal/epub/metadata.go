@@ -0,0 +1,246 @@
+package epub
+
+import (
+	"strings"
+)
+
+// Metadata represents the OPF <metadata> element
+type Metadata struct {
+	Titles       []DCElement   `xml:"title"`
+	Creators     []DCElement   `xml:"creator"`
+	Contributors []DCElement   `xml:"contributor"`
+	Languages    []DCElement   `xml:"language"`
+	Publishers   []DCElement   `xml:"publisher"`
+	Identifiers  []DCElement   `xml:"identifier"`
+	Dates        []DCElement   `xml:"date"`
+	Subjects     []DCElement   `xml:"subject"`
+	Descriptions []DCElement   `xml:"description"`
+	Rights       []DCElement   `xml:"rights"`
+	Metas        []MetaElement `xml:"meta"`
+}
+
+// DCElement is a Dublin Core element. Role, FileAs, Scheme and Event are the
+// EPUB 2 opf: attributes; EPUB 3 expresses them as refining <meta> elements.
+type DCElement struct {
+	ID     string `xml:"id,attr"`
+	Value  string `xml:",chardata"`
+	Role   string `xml:"role,attr"`
+	FileAs string `xml:"file-as,attr"`
+	Scheme string `xml:"scheme,attr"`
+	Event  string `xml:"event,attr"`
+}
+
+// MetaElement is an OPF <meta>, either EPUB 2 (name/content) or EPUB 3 (property/refines)
+type MetaElement struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Scheme   string `xml:"scheme,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// BookMetadata is the resolved bibliographic information of a book
+type BookMetadata struct {
+	Title            string
+	Subtitle         string
+	Creators         []Person
+	Contributors     []Person
+	Languages        []string
+	Publisher        string
+	Identifiers      []Identifier
+	UniqueIdentifier string // Value of the identifier named by the package's unique-identifier
+	Date             string
+	Modified         string
+	Subjects         []string
+	Description      string
+	Rights           string
+}
+
+// Person is a creator or contributor
+type Person struct {
+	Name   string
+	FileAs string // Sort form, e.g. "Austen, Jane"
+	Role   string // MARC relator code, e.g. "aut", "edt", "ill"
+}
+
+// Identifier is a book identifier such as an ISBN or UUID
+type Identifier struct {
+	Value  string
+	Scheme string // e.g. "ISBN", "UUID", "DOI"
+}
+
+// Authors returns the creators with the author role (or no role at all)
+func (m BookMetadata) Authors() []Person {
+	var authors []Person
+	for _, p := range m.Creators {
+		if p.Role == "" || p.Role == "aut" {
+			authors = append(authors, p)
+		}
+	}
+	if len(authors) == 0 {
+		return m.Creators
+	}
+	return authors
+}
+
+// ISBN returns the first ISBN identifier, or ""
+func (m BookMetadata) ISBN() string {
+	for _, id := range m.Identifiers {
+		if strings.EqualFold(id.Scheme, "ISBN") {
+			return id.Value
+		}
+	}
+	return ""
+}
+
+// resolveMetadata merges the raw OPF metadata with its EPUB 3 refinements
+func resolveMetadata(raw Metadata, uniqueID string) BookMetadata {
+	// Collect refinements: "#id" -> property -> values
+	refines := make(map[string]map[string][]string)
+	var meta BookMetadata
+	for _, m := range raw.Metas {
+		value := strings.TrimSpace(m.Value)
+		if m.Refines != "" {
+			id := strings.TrimPrefix(m.Refines, "#")
+			if refines[id] == nil {
+				refines[id] = make(map[string][]string)
+			}
+			refines[id][m.Property] = append(refines[id][m.Property], value)
+			continue
+		}
+		if m.Property == "dcterms:modified" {
+			meta.Modified = value
+		}
+	}
+	refined := func(e DCElement, property string) string {
+		if values := refines[e.ID][property]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	// Titles: prefer title-type "main", and use a "subtitle" if one is declared
+	for _, t := range raw.Titles {
+		value := collapseSpace(t.Value)
+		switch refined(t, "title-type") {
+		case "main":
+			meta.Title = value
+		case "subtitle":
+			if meta.Subtitle == "" {
+				meta.Subtitle = value
+			}
+		}
+	}
+	// Without a main title, a subtitle, short title or the title of the
+	// series the book belongs to is the title only if there's nothing else
+	if meta.Title == "" {
+		for _, t := range raw.Titles {
+			switch refined(t, "title-type") {
+			case "subtitle", "short", "collection":
+				continue
+			}
+			meta.Title = collapseSpace(t.Value)
+			break
+		}
+	}
+	if meta.Title == "" && len(raw.Titles) > 0 {
+		meta.Title = collapseSpace(raw.Titles[0].Value)
+	}
+
+	people := func(elements []DCElement) []Person {
+		var list []Person
+		for _, e := range elements {
+			p := Person{
+				Name:   collapseSpace(e.Value),
+				FileAs: firstNonEmpty(refined(e, "file-as"), e.FileAs),
+				Role:   firstNonEmpty(refined(e, "role"), e.Role),
+			}
+			if p.Name != "" {
+				list = append(list, p)
+			}
+		}
+		return list
+	}
+	meta.Creators = people(raw.Creators)
+	meta.Contributors = people(raw.Contributors)
+
+	for _, l := range raw.Languages {
+		if v := strings.TrimSpace(l.Value); v != "" {
+			meta.Languages = append(meta.Languages, v)
+		}
+	}
+
+	for _, id := range raw.Identifiers {
+		value := strings.TrimSpace(id.Value)
+		if value == "" {
+			continue
+		}
+		if id.ID == uniqueID {
+			meta.UniqueIdentifier = value
+		}
+		meta.Identifiers = append(meta.Identifiers, Identifier{
+			Value:  strings.TrimPrefix(strings.TrimPrefix(value, "urn:isbn:"), "urn:uuid:"),
+			Scheme: identifierScheme(value, firstNonEmpty(refined(id, "identifier-type"), id.Scheme)),
+		})
+	}
+
+	// Prefer the publication date when EPUB 2 lists several events
+	for _, d := range raw.Dates {
+		if meta.Date == "" || d.Event == "publication" {
+			meta.Date = strings.TrimSpace(d.Value)
+		}
+	}
+
+	for _, s := range raw.Subjects {
+		if v := collapseSpace(s.Value); v != "" {
+			meta.Subjects = append(meta.Subjects, v)
+		}
+	}
+	if len(raw.Publishers) > 0 {
+		meta.Publisher = collapseSpace(raw.Publishers[0].Value)
+	}
+	if len(raw.Descriptions) > 0 {
+		meta.Description = cleanLabel(raw.Descriptions[0].Value)
+	}
+	if len(raw.Rights) > 0 {
+		meta.Rights = collapseSpace(raw.Rights[0].Value)
+	}
+
+	return meta
+}
+
+// identifierScheme names the identifier scheme from an explicit scheme or the URN prefix
+func identifierScheme(value, scheme string) string {
+	lower := strings.ToLower(value)
+	switch {
+	case strings.HasPrefix(lower, "urn:isbn:"):
+		return "ISBN"
+	case strings.HasPrefix(lower, "urn:uuid:"):
+		return "UUID"
+	case strings.HasPrefix(lower, "urn:doi:"), strings.HasPrefix(lower, "doi:"):
+		return "DOI"
+	}
+
+	// ONIX codes used by EPUB 3 identifier-type refinements
+	switch scheme {
+	case "15", "02":
+		return "ISBN"
+	case "06":
+		return "DOI"
+	}
+	return strings.ToUpper(scheme)
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package epub
+
+import "testing"
+
+func TestResolveTitle(t *testing.T) {
+	// title is a dc:title refined with a title-type, or untyped when empty
+	type title struct{ value, titleType string }
+	tests := []struct {
+		name            string
+		titles          []title
+		title, subtitle string
+	}{
+		{"single", []title{{"Moby-Dick", ""}}, "Moby-Dick", ""},
+		{"main wins", []title{{"Sea Stories", "collection"}, {"Moby-Dick", "main"}, {"The Whale", "subtitle"}},
+			"Moby-Dick", "The Whale"},
+		{"untyped after a collection", []title{{"Sea Stories", "collection"}, {"Moby-Dick", ""}}, "Moby-Dick", ""},
+		{"skips subtitle and short", []title{{"The Whale", "subtitle"}, {"Moby", "short"}, {"Moby-Dick; or,  The Whale", "expanded"}},
+			"Moby-Dick; or, The Whale", "The Whale"},
+		{"nothing but skipped types", []title{{"Sea Stories", "collection"}, {"Moby", "short"}}, "Sea Stories", ""},
+		{"none", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw Metadata
+			for i, ti := range tt.titles {
+				id := string(rune('a' + i))
+				raw.Titles = append(raw.Titles, DCElement{ID: id, Value: ti.value})
+				if ti.titleType != "" {
+					raw.Metas = append(raw.Metas, MetaElement{Refines: "#" + id, Property: "title-type", Value: ti.titleType})
+				}
+			}
+			meta := resolveMetadata(raw, "")
+			if meta.Title != tt.title || meta.Subtitle != tt.subtitle {
+				t.Errorf("title %q, subtitle %q, want %q, %q", meta.Title, meta.Subtitle, tt.title, tt.subtitle)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package epub
+
+import (
+	"strconv"
+	"strings"
+)
+
+// cssPageSizes are the page size keywords of CSS Paged Media, in inches
+var cssPageSizes = map[string][2]float64{
+	"a5":     {5.83, 8.27},
+	"a4":     {8.27, 11.69},
+	"a3":     {11.69, 16.54},
+	"b5":     {6.93, 9.84},
+	"b4":     {9.84, 13.9},
+	"jis-b5": {7.17, 10.12},
+	"jis-b4": {10.12, 14.33},
+	"letter": {8.5, 11},
+	"legal":  {8.5, 14},
+	"ledger": {11, 17},
+}
+
+// cssUnits are the absolute CSS length units, in inches
+var cssUnits = map[string]float64{
+	"in": 1,
+	"cm": 1 / 2.54,
+	"mm": 1 / 25.4,
+	"q":  1 / 101.6,
+	"pt": 1.0 / 72,
+	"pc": 1.0 / 6,
+	"px": 1.0 / 96,
+}
+
+// PageSize returns the page size in inches set by the book's own @page
+// rules. Only rules for every page count, and the last one wins as in the
+// cascade; ok is false when the book leaves the size to the reader.
+func (b *Book) PageSize() (width, height float64, ok bool) {
+	var sheets []string
+	for _, sheet := range b.CSS {
+		sheets = append(sheets, sheet.Content)
+	}
+	for _, chapter := range b.Chapters {
+		sheets = append(sheets, chapter.Styles...)
+	}
+
+	for _, css := range sheets {
+		for _, block := range pageRules(css) {
+			if w, h, declared := pageRuleSize(block); declared {
+				width, height, ok = w, h, w > 0
+			}
+		}
+	}
+	return width, height, ok
+}
+
+// pageRules returns the blocks of the top-level @page rules without a page
+// selector
+func pageRules(css string) []string {
+	var blocks []string
+	for i := 0; i < len(css); {
+		end := scanCSS(css, i, "{;")
+		if end >= len(css) {
+			break
+		}
+		if css[end] == ';' {
+			i = end + 1
+			continue
+		}
+		blockEnd := matchingBrace(css, end)
+		prelude := strings.ToLower(strings.TrimSpace(stripCSSComments(css[i:end])))
+		if prelude == "@page" && blockEnd < len(css) {
+			blocks = append(blocks, css[end+1:blockEnd])
+		}
+		i = blockEnd + 1
+	}
+	return blocks
+}
+
+// pageRuleSize parses the size declaration of a @page block. The size is
+// zero when it is declared but left to the reader, as with auto.
+func pageRuleSize(block string) (width, height float64, declared bool) {
+	var value string
+	for _, decl := range strings.Split(stripCSSComments(block), ";") {
+		name, v, found := strings.Cut(decl, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "size") {
+			value = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "!important")))
+			declared = true
+		}
+	}
+	if !declared {
+		return 0, 0, false
+	}
+
+	var lengths []float64
+	orientation := ""
+	for _, field := range strings.Fields(value) {
+		switch {
+		case field == "portrait" || field == "landscape":
+			orientation = field
+		case field == "auto":
+		case cssPageSizes[field] != [2]float64{}:
+			size := cssPageSizes[field]
+			lengths = append(lengths, size[0], size[1])
+		default:
+			length, valid := cssLength(field)
+			if !valid {
+				// Invalid declarations are dropped
+				return 0, 0, false
+			}
+			lengths = append(lengths, length)
+		}
+	}
+
+	switch len(lengths) {
+	case 1:
+		width, height = lengths[0], lengths[0]
+	case 2:
+		width, height = lengths[0], lengths[1]
+	default:
+		// auto, or an orientation alone: the size is up to the reader
+		return 0, 0, true
+	}
+	if orientation == "landscape" && width < height || orientation == "portrait" && width > height {
+		width, height = height, width
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, true
+	}
+	return width, height, true
+}
+
+// cssLength parses an absolute CSS length and returns it in inches
+func cssLength(s string) (float64, bool) {
+	i := len(s)
+	for i > 0 && (s[i-1] >= 'a' && s[i-1] <= 'z') {
+		i--
+	}
+	unit, ok := cssUnits[s[i:]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, false
+	}
+	return n * unit, true
+}
+
+// stripCSSComments removes /* */ comments
+func stripCSSComments(css string) string {
+	for {
+		start := strings.Index(css, "/*")
+		if start == -1 {
+			return css
+		}
+		end := strings.Index(css[start+2:], "*/")
+		if end == -1 {
+			return css[:start]
+		}
+		css = css[:start] + " " + css[start+2+end+2:]
+	}
+}
@@ -2,13 +2,11 @@ package epub
 
 import (
 	"archive/zip"
-	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/url"
 	"path"
-	"regexp"
 	"sort"
 	"strings"
 )
@@ -17,16 +15,45 @@ import (
 type Book struct {
 	Title    string
 	Author   string
+	Metadata BookMetadata
 	Chapters []Chapter
-	CSS      []string
+	TOC      []TOCEntry
+	CSS      []Stylesheet
 	BasePath string
+
+	FixedLayout bool // The package is pre-paginated (comics, picture books)
+
+	CoverImage string // Cover image path inside the EPUB archive (may be empty)
+	coverSrc   string // Cover image URL in the merged document
+
+	// Warnings are problems found while parsing that the book can be
+	// converted despite
+	Warnings []string
+
+	archive *archive
 }
 
 // Chapter represents a single chapter/section
 type Chapter struct {
 	Title   string
+	Path    string // Document path inside the EPUB archive
 	Content string
 	Order   int
+	IsCover bool // Document only displays the cover image
+
+	Fixed    bool     // Pre-paginated: rendered as exactly one page
+	Viewport Viewport // Size the fixed-layout document is designed for
+
+	Styles      []string       // Inline <style> blocks from the chapter's <head>
+	Stylesheets []string       // Paths of the stylesheets the chapter links
+	Body        BodyAttributes // Attributes of the chapter's <body>
+	ids         map[string]bool
+}
+
+// Stylesheet is a CSS file from the EPUB archive
+type Stylesheet struct {
+	Path    string
+	Content string
 }
 
 // Container represents the META-INF/container.xml structure
@@ -40,15 +67,12 @@ type Container struct {
 
 // Package represents the OPF package document
 type Package struct {
-	XMLName  xml.Name `xml:"package"`
-	Metadata Metadata `xml:"metadata"`
-	Manifest Manifest `xml:"manifest"`
-	Spine    Spine    `xml:"spine"`
-}
-
-type Metadata struct {
-	Title   string `xml:"title"`
-	Creator string `xml:"creator"`
+	XMLName          xml.Name `xml:"package"`
+	UniqueIdentifier string   `xml:"unique-identifier,attr"`
+	Metadata         Metadata `xml:"metadata"`
+	Manifest         Manifest `xml:"manifest"`
+	Spine            Spine    `xml:"spine"`
+	Guide            Guide    `xml:"guide"`
 }
 
 type Manifest struct {
@@ -56,32 +80,50 @@ type Manifest struct {
 }
 
 type ManifestItem struct {
-	ID        string `xml:"id,attr"`
-	Href      string `xml:"href,attr"`
-	MediaType string `xml:"media-type,attr"`
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
 }
 
 type Spine struct {
+	Toc      string         `xml:"toc,attr"`
 	ItemRefs []SpineItemRef `xml:"itemref"`
 }
 
 type SpineItemRef struct {
-	IDRef string `xml:"idref,attr"`
+	IDRef      string `xml:"idref,attr"`
+	Properties string `xml:"properties,attr"`
 }
 
-// Parse reads and parses an EPUB file
+// Parse reads and parses an EPUB file. The archive stays open so that
+// resources can be served while rendering; call Close when done.
 func Parse(epubPath string) (*Book, error) {
 	r, err := zip.OpenReader(epubPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open epub: %w", err)
 	}
-	defer r.Close()
+	book, err := parseArchive(newArchive(&r.Reader, r))
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return book, nil
+}
 
-	// Create a map for quick file lookup
-	files := make(map[string]*zip.File)
-	for _, f := range r.File {
-		files[f.Name] = f
+// ParseReader parses an EPUB of the given size read from r, which must
+// stay readable until the book is no longer used
+func ParseReader(r io.ReaderAt, size int64) (*Book, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open epub: %w", err)
 	}
+	return parseArchive(newArchive(zr, nil))
+}
+
+// parseArchive parses the book in an EPUB container
+func parseArchive(a *archive) (*Book, error) {
+	files := a.files
 
 	// Parse container.xml to find the OPF file
 	containerFile, ok := files["META-INF/container.xml"]
@@ -114,10 +156,38 @@ func Parse(epubPath string) (*Book, error) {
 		basePath = ""
 	}
 
+	metadata := resolveMetadata(pkg.Metadata, pkg.UniqueIdentifier)
+	var authors []string
+	for _, author := range metadata.Authors() {
+		authors = append(authors, author.Name)
+	}
+
+	fixedLayout, defaultViewport := packageLayout(pkg.Metadata)
+
 	book := &Book{
-		Title:    pkg.Metadata.Title,
-		Author:   pkg.Metadata.Creator,
-		BasePath: basePath,
+		Title:       metadata.Title,
+		Author:      strings.Join(authors, ", "),
+		Metadata:    metadata,
+		BasePath:    basePath,
+		FixedLayout: fixedLayout,
+		archive:     a,
+	}
+
+	// Obfuscated fonts are keyed from the package identifiers. Without
+	// them the fonts stay scrambled and the browser falls back to others.
+	if err := a.loadEncryption(metadata); err != nil {
+		book.Warnings = append(book.Warnings, fmt.Sprintf("embedded fonts left obfuscated: %v", err))
+	}
+
+	// Parse the navigation document (EPUB 3) or NCX (EPUB 2)
+	book.TOC = parseTOC(pkg, basePath, files)
+	titles := chapterTitles(book.TOC)
+
+	// Locate the cover image and the XHTML page that displays it
+	coverImage, coverPage := findCover(pkg, basePath, files)
+	if _, ok := files[coverImage]; ok {
+		book.CoverImage = coverImage
+		book.coverSrc = ResourceURL(coverImage)
 	}
 
 	// Build manifest lookup
@@ -129,16 +199,7 @@ func Parse(epubPath string) (*Book, error) {
 	// Extract CSS files
 	for _, item := range pkg.Manifest.Items {
 		if item.MediaType == "text/css" {
-			cssPath := resolvePath(basePath, item.Href)
-			if cssFile, ok := files[cssPath]; ok {
-				content, err := readFileContent(cssFile)
-				if err == nil {
-					// Embed images referenced in CSS (background-image, etc.)
-					cssDir := path.Dir(cssPath)
-					content = embedImages(content, cssDir, files)
-					book.CSS = append(book.CSS, content)
-				}
-			}
+			book.loadStylesheet(resolvePath(basePath, item.Href), a)
 		}
 	}
 
@@ -164,15 +225,59 @@ func Parse(epubPath string) (*Book, error) {
 			continue
 		}
 
-		// Process images to embed as base64
-		// Use the chapter's directory as the base for resolving relative image paths
-		chapterDir := path.Dir(chapterPath)
-		content = embedImages(content, chapterDir, files)
+		isCover := isCoverDocument(chapterPath, content, coverPage, book.CoverImage)
+
+		// Fixed-layout documents keep their designed page size
+		fixed := itemLayout(itemRef.Properties, fixedLayout)
+		var viewport Viewport
+		if fixed {
+			if viewport = parseViewport(content); !viewport.valid() {
+				viewport = defaultViewport
+			}
+		}
+
+		// Keep the chapter's own styles and <body> attributes
+		styles, links, body := parseChapterHead(content)
+		var stylesheets []string
+		for _, href := range links {
+			cssPath, _ := resolveHref(path.Dir(chapterPath), href)
+			if book.loadStylesheet(cssPath, a) {
+				stylesheets = append(stylesheets, cssPath)
+			}
+		}
+
+		// Resolve the resources of the chapter's inline styles; the markup
+		// itself is rewritten once all chapters are known
+		order := i
+		css := cssRewriter{a: a, baseDir: path.Dir(chapterPath), anchor: func(id string) string {
+			return namespacedID(order, id)
+		}}
+		for j := range styles {
+			styles[j] = css.rewrite(styles[j])
+		}
+
+		// Prefer the TOC label, then the document's own <title>
+		title, ok := titles[chapterPath]
+		if !ok {
+			title = documentTitle(content)
+		}
+		if title == "" {
+			title = item.ID
+		}
 
 		book.Chapters = append(book.Chapters, Chapter{
-			Title:   item.ID,
+			Title:   title,
+			Path:    chapterPath,
 			Content: content,
 			Order:   i,
+			IsCover: isCover,
+
+			Fixed:    fixed,
+			Viewport: viewport,
+
+			Styles:      styles,
+			Stylesheets: stylesheets,
+			Body:        body,
 		})
 	}
 
@@ -181,9 +286,43 @@ func Parse(epubPath string) (*Book, error) {
 		return book.Chapters[i].Order < book.Chapters[j].Order
 	})
 
+	// Point links between chapters at anchors in the merged document and
+	// resolve resource references
+	book.rewriteChapters(a)
+
 	return book, nil
 }
 
+// Close releases the EPUB archive
+func (b *Book) Close() error {
+	return b.archive.Close()
+}
+
+// loadStylesheet adds a CSS file to the book unless it is already loaded,
+// reporting whether the stylesheet is available
+func (b *Book) loadStylesheet(cssPath string, a *archive) bool {
+	for _, sheet := range b.CSS {
+		if sheet.Path == cssPath {
+			return true
+		}
+	}
+
+	cssFile, ok := a.files[cssPath]
+	if !ok {
+		return false
+	}
+	content, err := readFileContent(cssFile)
+	if err != nil {
+		return false
+	}
+
+	// Inline @import rules and resolve url() references (backgrounds, @font-face, ...)
+	css := cssRewriter{a: a, baseDir: path.Dir(cssPath), seen: map[string]bool{cssPath: true}}
+	content = css.rewrite(content)
+	b.CSS = append(b.CSS, Stylesheet{Path: cssPath, Content: content})
+	return true
+}
+
 func parseContainer(f *zip.File) (*Container, error) {
 	rc, err := f.Open()
 	if err != nil {
@@ -230,139 +369,16 @@ func readFileContent(f *zip.File) (string, error) {
 }
 
 func resolvePath(basePath, href string) string {
+	// Manifest hrefs are URLs, so "ch%202.xhtml" names "ch 2.xhtml" in the archive
+	if decoded, err := url.PathUnescape(href); err == nil {
+		href = decoded
+	}
 	if basePath == "" {
 		return href
 	}
 	return path.Join(basePath, href)
 }
 
-func embedImages(content, basePath string, files map[string]*zip.File) string {
-	// Regular expressions to find image sources
-	// Match src="..." or src='...' in img tags
-	imgSrcRegex := regexp.MustCompile(`(?i)(<img[^>]*\ssrc\s*=\s*)(["'])([^"']+)(["'])`)
-	
-	// Match xlink:href="..." for SVG images
-	xlinkRegex := regexp.MustCompile(`(?i)(xlink:href\s*=\s*)(["'])([^"']+)(["'])`)
-	
-	// Match url(...) in inline styles for background images
-	urlRegex := regexp.MustCompile(`(?i)(url\s*\(\s*)(["']?)([^"')]+)(["']?\s*\))`)
-
-	// Process img src attributes
-	content = imgSrcRegex.ReplaceAllStringFunc(content, func(match string) string {
-		parts := imgSrcRegex.FindStringSubmatch(match)
-		if len(parts) < 5 {
-			return match
-		}
-		prefix := parts[1]  // <img ... src=
-		quote := parts[2]   // " or '
-		src := parts[3]     // the actual path
-		endQuote := parts[4]
-
-		dataURI := resolveAndEmbed(src, basePath, files)
-		if dataURI != "" {
-			return prefix + quote + dataURI + endQuote
-		}
-		return match
-	})
-
-	// Process xlink:href for SVG
-	content = xlinkRegex.ReplaceAllStringFunc(content, func(match string) string {
-		parts := xlinkRegex.FindStringSubmatch(match)
-		if len(parts) < 5 {
-			return match
-		}
-		prefix := parts[1]
-		quote := parts[2]
-		src := parts[3]
-		endQuote := parts[4]
-
-		// Only process image files
-		if isImageFile(src) {
-			dataURI := resolveAndEmbed(src, basePath, files)
-			if dataURI != "" {
-				return prefix + quote + dataURI + endQuote
-			}
-		}
-		return match
-	})
-
-	// Process url() in styles
-	content = urlRegex.ReplaceAllStringFunc(content, func(match string) string {
-		parts := urlRegex.FindStringSubmatch(match)
-		if len(parts) < 5 {
-			return match
-		}
-		prefix := parts[1]  // url(
-		quote := parts[2]   // optional quote
-		src := parts[3]     // the path
-		suffix := parts[4]  // optional quote + )
-
-		// Only process image files
-		if isImageFile(src) {
-			dataURI := resolveAndEmbed(src, basePath, files)
-			if dataURI != "" {
-				return prefix + quote + dataURI + suffix
-			}
-		}
-		return match
-	})
-
-	return content
-}
-
-func resolveAndEmbed(src, basePath string, files map[string]*zip.File) string {
-	// Skip data URIs and external URLs
-	if strings.HasPrefix(src, "data:") || strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
-		return ""
-	}
-
-	// Clean up the path (remove fragment identifiers)
-	cleanSrc := src
-	if idx := strings.Index(cleanSrc, "#"); idx != -1 {
-		cleanSrc = cleanSrc[:idx]
-	}
-
-	// URL decode the path (handle %20 for spaces, etc.)
-	decodedSrc, err := url.QueryUnescape(cleanSrc)
-	if err == nil {
-		cleanSrc = decodedSrc
-	}
-
-	// Resolve relative path
-	imagePath := resolveRelativePath(basePath, cleanSrc)
-
-	// Try to find the file
-	zipFile, ok := files[imagePath]
-	if !ok {
-		// Try without basePath
-		zipFile, ok = files[cleanSrc]
-		if !ok {
-			// Try normalizing the path further
-			normalizedPath := normalizePath(imagePath)
-			zipFile, ok = files[normalizedPath]
-			if !ok {
-				return ""
-			}
-		}
-	}
-
-	// Read the file content
-	data, err := readBinaryContent(zipFile)
-	if err != nil {
-		return ""
-	}
-
-	// Determine MIME type
-	mimeType := getMimeType(cleanSrc)
-	if mimeType == "" {
-		return ""
-	}
-
-	// Create data URI
-	encoded := base64.StdEncoding.EncodeToString(data)
-	return fmt.Sprintf("data:%s;base64,%s", mimeType, encoded)
-}
-
 func resolveRelativePath(basePath, href string) string {
 	if basePath == "" {
 		return href
@@ -384,26 +400,6 @@ func normalizePath(p string) string {
 	return strings.TrimPrefix(cleaned, "/")
 }
 
-func readBinaryContent(f *zip.File) ([]byte, error) {
-	rc, err := f.Open()
-	if err != nil {
-		return nil, err
-	}
-	defer rc.Close()
-	return io.ReadAll(rc)
-}
-
-func isImageFile(src string) bool {
-	lower := strings.ToLower(src)
-	extensions := []string{".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".bmp", ".ico"}
-	for _, ext := range extensions {
-		if strings.HasSuffix(lower, ext) {
-			return true
-		}
-	}
-	return false
-}
-
 func getMimeType(filename string) string {
 	lower := strings.ToLower(filename)
 	switch {
@@ -421,116 +417,15 @@ func getMimeType(filename string) string {
 		return "image/bmp"
 	case strings.HasSuffix(lower, ".ico"):
 		return "image/x-icon"
+	case strings.HasSuffix(lower, ".ttf"):
+		return "font/ttf"
+	case strings.HasSuffix(lower, ".otf"):
+		return "font/otf"
+	case strings.HasSuffix(lower, ".woff"):
+		return "font/woff"
+	case strings.HasSuffix(lower, ".woff2"):
+		return "font/woff2"
 	default:
 		return ""
 	}
 }
-
-// ToHTML converts the book to a single HTML document
-func (b *Book) ToHTML() string {
-	var sb strings.Builder
-
-	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
-	sb.WriteString("<meta charset=\"UTF-8\">\n")
-	sb.WriteString(fmt.Sprintf("<title>%s</title>\n", escapeHTML(b.Title)))
-	
-	// Embed CSS
-	sb.WriteString("<style>\n")
-	sb.WriteString(`
-		body {
-			font-family: Georgia, 'Times New Roman', serif;
-			line-height: 1.6;
-			max-width: 800px;
-			margin: 0 auto;
-			padding: 40px 20px;
-			color: #333;
-		}
-		h1, h2, h3, h4, h5, h6 {
-			margin-top: 1.5em;
-			margin-bottom: 0.5em;
-		}
-		p {
-			margin: 0.8em 0;
-			text-align: justify;
-		}
-		img {
-			max-width: 100%;
-			height: auto;
-		}
-		.chapter {
-			page-break-before: always;
-		}
-		.chapter:first-child {
-			page-break-before: avoid;
-		}
-		.title-page {
-			text-align: center;
-			padding: 100px 0;
-		}
-		.title-page h1 {
-			font-size: 2.5em;
-			margin-bottom: 0.5em;
-		}
-		.title-page .author {
-			font-size: 1.3em;
-			color: #666;
-		}
-	`)
-	for _, css := range b.CSS {
-		sb.WriteString(css)
-		sb.WriteString("\n")
-	}
-	sb.WriteString("</style>\n")
-	sb.WriteString("</head>\n<body>\n")
-
-	// Title page
-	sb.WriteString("<div class=\"title-page\">\n")
-	sb.WriteString(fmt.Sprintf("<h1>%s</h1>\n", escapeHTML(b.Title)))
-	if b.Author != "" {
-		sb.WriteString(fmt.Sprintf("<p class=\"author\">%s</p>\n", escapeHTML(b.Author)))
-	}
-	sb.WriteString("</div>\n")
-
-	// Chapters
-	for _, chapter := range b.Chapters {
-		sb.WriteString("<div class=\"chapter\">\n")
-		// Extract body content if it's a full HTML document
-		content := extractBodyContent(chapter.Content)
-		sb.WriteString(content)
-		sb.WriteString("\n</div>\n")
-	}
-
-	sb.WriteString("</body>\n</html>")
-
-	return sb.String()
-}
-
-func extractBodyContent(html string) string {
-	// Try to extract just the body content
-	bodyStart := strings.Index(strings.ToLower(html), "<body")
-	if bodyStart == -1 {
-		return html
-	}
-
-	// Find the end of the opening body tag
-	bodyTagEnd := strings.Index(html[bodyStart:], ">")
-	if bodyTagEnd == -1 {
-		return html
-	}
-	bodyStart = bodyStart + bodyTagEnd + 1
-
-	bodyEnd := strings.LastIndex(strings.ToLower(html), "</body>")
-	if bodyEnd == -1 {
-		bodyEnd = len(html)
-	}
-
-	return html[bodyStart:bodyEnd]
-}
-
-func escapeHTML(s string) string {
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	s = strings.ReplaceAll(s, "\"", "&quot;")
-	return s
-}
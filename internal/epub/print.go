@@ -0,0 +1,48 @@
+package epub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// printCSS starts the front matter and every chapter on a right-hand page,
+// as in a printed book; Chrome inserts a blank left-hand page when needed
+const printCSS = `
+		.title-page, .toc-page, .chapter, .chapter:first-child {
+			break-before: right;
+		}
+	`
+
+// trimOffset returns the paper around the trimmed page in inches
+func (o HTMLOptions) trimOffset() float64 {
+	return o.Bleed + o.Slug
+}
+
+// bleedSize returns the size in inches of full-bleed pages: the page and
+// its bleed
+func (o HTMLOptions) bleedSize() (float64, float64) {
+	return o.PageWidth + 2*o.Bleed, o.PageHeight + 2*o.Bleed
+}
+
+// writePageCSS writes the page margins, mirrored on left-hand pages, and in
+// print mode the recto chapter starts. The margins are measured from the
+// trimmed page, so they grow by the bleed and slug. When the book's @page
+// rules are preferred, they are left to set the margins.
+func writePageCSS(sb *strings.Builder, opts HTMLOptions) {
+	offset := opts.trimOffset()
+	inside, outside := opts.InsideMargin+offset, opts.OutsideMargin+offset
+	if opts.Print && !opts.PreferCSSPageSize {
+		sb.WriteString(fmt.Sprintf("@page :right { margin-left: %.3fin; margin-right: %.3fin; }\n", inside, outside))
+	}
+	if (opts.Print || inside != outside) && !opts.PreferCSSPageSize {
+		sb.WriteString(fmt.Sprintf("@page :left { margin-left: %.3fin; margin-right: %.3fin; }\n", outside, inside))
+	}
+
+	if opts.Print {
+		sb.WriteString(printCSS)
+		if opts.Slug > 0 {
+			// The cover bleeds to the edge of the bleed area, not the paper
+			sb.WriteString(fmt.Sprintf("@page epub2pdf-cover { margin: %.3fin; }\n", opts.Slug))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package epub
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ResourcePrefix is the path, relative to the merged document, under which
+// the files of the archive are referenced
+const ResourcePrefix = "epub/"
+
+// ResourceURL returns the URL the merged document uses for a file of the
+// archive. URLs are relative so the document can be served under any path.
+func ResourceURL(name string) string {
+	return ResourcePrefix + (&url.URL{Path: name}).EscapedPath()
+}
+
+// ResourceHandler serves the files of the archive, streamed from the zip on
+// demand. Request paths are archive paths: mount it with http.StripPrefix.
+// Files that exist but can't be read, such as corrupt entries, are passed
+// to failed, which may be called concurrently.
+func (b *Book) ResourceHandler(failed func(name string, err error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if _, ok := b.archive.files[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		rc, err := b.archive.open(name)
+		if err != nil {
+			failed(name, err)
+			http.Error(w, "failed to read "+name, http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+
+		if mimeType := contentType(name); mimeType != "" {
+			w.Header().Set("Content-Type", mimeType)
+		}
+		// Write errors only mean the browser stopped reading
+		src := &errorReader{Reader: rc}
+		io.Copy(w, src)
+		if src.err != nil {
+			failed(name, src.err)
+		}
+	})
+}
+
+// errorReader keeps the first error other than io.EOF from its reader
+type errorReader struct {
+	io.Reader
+	err error
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// contentType returns the MIME type of a file, or "" to let net/http sniff it
+func contentType(name string) string {
+	if mimeType := getMimeType(name); mimeType != "" {
+		return mimeType
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".xhtml":
+		return "application/xhtml+xml"
+	case ".css":
+		return "text/css; charset=utf-8"
+	}
+	return mime.TypeByExtension(path.Ext(name))
+}
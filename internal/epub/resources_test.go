@@ -0,0 +1,63 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResourceHandler(t *testing.T) {
+	// A stored entry whose checksum doesn't match its data fails at the end
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, crc := range map[string]uint32{"OEBPS/font.otf": crc32.ChecksumIEEE([]byte("hello")), "OEBPS/corrupt.png": 1} {
+		f, err := w.CreateRaw(&zip.FileHeader{Name: name, Method: zip.Store, CRC32: crc, CompressedSize64: 5, UncompressedSize64: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte("hello"))
+	}
+	w.Close()
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newArchive(r, nil)
+	a.obfuscated = map[string]string{"OEBPS/font.otf": "urn:unknown"}
+	b := &Book{archive: a}
+
+	tests := []struct {
+		name   string
+		status int
+		failed string // Start of the reported error, "" for none
+	}{
+		{"OEBPS/corrupt.png", http.StatusOK, "zip: checksum error"},
+		{"OEBPS/font.otf", http.StatusInternalServerError, "OEBPS/font.otf: unsupported encryption algorithm"},
+		{"OEBPS/missing.png", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		var failures []string
+		handler := b.ResourceHandler(func(name string, err error) {
+			if name != tt.name {
+				t.Errorf("failure reported for %s, want %s", name, tt.name)
+			}
+			failures = append(failures, err.Error())
+		})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/"+tt.name, nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		switch {
+		case tt.failed == "" && len(failures) > 0:
+			t.Errorf("%s: reported %q", tt.name, failures)
+		case tt.failed != "" && (len(failures) != 1 || !strings.HasPrefix(failures[0], tt.failed)):
+			t.Errorf("%s: reported %q, want one %q", tt.name, failures, tt.failed)
+		}
+	}
+}
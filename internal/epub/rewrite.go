@@ -0,0 +1,431 @@
+package epub
+
+import (
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// voidElements never have content, so "<br/>" and "<br>" are the same tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true,
+	"track": true, "wbr": true,
+}
+
+// svgURLAttributes are SVG presentation attributes that may hold url(#id)
+var svgURLAttributes = map[string]bool{
+	"fill": true, "stroke": true, "clip-path": true, "mask": true, "filter": true,
+	"marker-start": true, "marker-mid": true, "marker-end": true,
+}
+
+// rewriteChapters rewrites the markup of every chapter. This runs once all
+// chapters are known so that links between them can be resolved.
+func (b *Book) rewriteChapters(a *archive) {
+	for i := range b.Chapters {
+		b.rewriteChapter(&b.Chapters[i], a)
+	}
+}
+
+// rewriteChapter walks a chapter's markup with an HTML tokenizer. It
+// namespaces ids, points links at anchors in the merged document and
+// resolves resource references (src, srcset, poster, SVG href, url() in
+// styles, ...) against the archive. Text, comments and tags that need no
+// change are copied verbatim.
+func (b *Book) rewriteChapter(chapter *Chapter, a *archive) {
+	baseDir := path.Dir(chapter.Path)
+	chapter.ids = make(map[string]bool)
+	css := cssRewriter{a: a, baseDir: baseDir, anchor: func(id string) string {
+		return namespacedID(chapter.Order, id)
+	}}
+
+	var out strings.Builder
+	z := html.NewTokenizer(strings.NewReader(chapter.Content))
+	foreign := 0 // Depth inside <svg> and <math>, where "<x/>" is self-closing
+	picture := 0 // Depth inside <picture>, whose <source> elements hold images
+	inStyle := false
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := string(z.Raw())
+
+		switch tt {
+		case html.TextToken:
+			if inStyle {
+				raw = css.rewrite(raw)
+			}
+			out.WriteString(raw)
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			changed := b.rewriteAttributes(&tok, chapter, picture > 0, css)
+			selfClosing := tt == html.SelfClosingTagToken
+
+			switch {
+			case selfClosing && foreign == 0 && !voidElements[tok.Data]:
+				// XHTML "<a id="x"/>" would swallow the following content
+				// once parsed as HTML, so close the element explicitly
+				z.NextIsNotRawText()
+				writeTag(&out, tok, false)
+				out.WriteString("</" + tok.Data + ">")
+			case changed:
+				writeTag(&out, tok, selfClosing)
+			default:
+				out.WriteString(raw)
+			}
+
+			if !selfClosing {
+				switch tok.Data {
+				case "svg", "math":
+					foreign++
+				case "picture":
+					picture++
+				case "style":
+					inStyle = true
+				}
+			}
+
+		case html.EndTagToken:
+			switch name, _ := z.TagName(); string(name) {
+			case "svg", "math":
+				if foreign > 0 {
+					foreign--
+				}
+			case "picture":
+				if picture > 0 {
+					picture--
+				}
+			case "style":
+				inStyle = false
+			}
+			out.WriteString(raw)
+
+		default:
+			out.WriteString(raw)
+		}
+	}
+
+	chapter.Content = out.String()
+}
+
+// rewriteAttributes rewrites the attributes of a start tag in place,
+// reporting whether any of them changed
+func (b *Book) rewriteAttributes(tok *html.Token, chapter *Chapter, inPicture bool, css cssRewriter) bool {
+	name := tok.Data
+	changed := false
+
+	attrs := tok.Attr[:0]
+	for _, attr := range tok.Attr {
+		key, value := attr.Key, attr.Val
+
+		switch {
+		case key == "id", key == "name" && name == "a":
+			chapter.ids[value] = true
+			value = namespacedID(chapter.Order, value)
+
+		case key == "style", svgURLAttributes[key] && strings.Contains(value, "url("):
+			value = css.rewrite(value)
+
+		case isResourceAttribute(name, key, inPicture):
+			if key == "srcset" {
+				value = css.a.rewriteSrcset(value, css.baseDir)
+			} else if src := css.a.resourceURL(value, css.baseDir); src != "" {
+				value = src
+			}
+
+		case (key == "href" || key == "xlink:href") && name != "link" && name != "base":
+			if target, ok := b.linkTarget(css.baseDir, chapter.Order, value); ok {
+				if target == "" {
+					// A dead link: the element stays, with its text
+					changed = true
+					continue
+				}
+				value = target
+			}
+		}
+
+		if value != attr.Val {
+			attr.Val = value
+			changed = true
+		}
+		attrs = append(attrs, attr)
+	}
+	tok.Attr = attrs
+	return changed
+}
+
+// isResourceAttribute reports whether an attribute references a file that
+// is displayed as part of the element (as opposed to a link)
+func isResourceAttribute(element, key string, inPicture bool) bool {
+	switch key {
+	case "src":
+		return element == "img" || element == "input" || element == "embed" ||
+			(element == "source" && inPicture)
+	case "srcset":
+		return element == "img" || element == "source"
+	case "poster":
+		return element == "video"
+	case "data":
+		return element == "object"
+	case "href", "xlink:href":
+		return element == "image" || element == "feimage"
+	}
+	return false
+}
+
+// writeTag serializes a start tag
+func writeTag(sb *strings.Builder, tok html.Token, selfClosing bool) {
+	sb.WriteString("<" + tok.Data)
+	for _, attr := range tok.Attr {
+		key := attr.Key
+		if attr.Namespace != "" {
+			key = attr.Namespace + ":" + key
+		}
+		sb.WriteString(" " + key + "=\"" + html.EscapeString(attr.Val) + "\"")
+	}
+	if selfClosing {
+		sb.WriteString("/")
+	}
+	sb.WriteString(">")
+}
+
+// rewriteSrcset resolves every image candidate of a srcset attribute
+func (a *archive) rewriteSrcset(srcset, baseDir string) string {
+	var candidates []string
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			break
+		}
+
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end == -1 {
+			end = len(s)
+		}
+		src, descriptor := s[:end], ""
+		s = s[end:]
+		if strings.HasSuffix(src, ",") {
+			src = strings.TrimRight(src, ",")
+		} else {
+			// Descriptors ("2x", "640w") run to the next comma
+			end = scanCSS(s, 0, ",")
+			descriptor = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+
+		if resolved := a.resourceURL(src, baseDir); resolved != "" {
+			src = resolved
+		}
+		if descriptor != "" {
+			src += " " + descriptor
+		}
+		candidates = append(candidates, src)
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// resourceURL returns the URL the merged document uses for a resource
+// referenced from baseDir, or "" when it is not a file in the archive
+func (a *archive) resourceURL(src, baseDir string) string {
+	src = strings.TrimSpace(src)
+	if src == "" || strings.HasPrefix(src, "#") || strings.HasPrefix(src, "//") || schemeRegex.MatchString(src) {
+		return ""
+	}
+
+	name, ok := a.find(src, baseDir)
+	if !ok {
+		return ""
+	}
+	if idx := strings.IndexByte(src, '#'); idx != -1 {
+		return ResourceURL(name) + src[idx:]
+	}
+	return ResourceURL(name)
+}
+
+// cssRewriter resolves the resources a stylesheet references. @import rules
+// are replaced by the imported stylesheet so that its rules are scoped and
+// its own references resolved like any others.
+type cssRewriter struct {
+	a       *archive
+	baseDir string
+	anchor  func(id string) string // Maps url(#id) to an element id; nil for stylesheet files
+	seen    map[string]bool        // Stylesheets being imported, to break @import cycles
+}
+
+// rewrite returns css with its url() references and @import rules resolved.
+// Strings and comments are copied as they are.
+func (r cssRewriter) rewrite(css string) string {
+	var out strings.Builder
+	i := 0
+	for i < len(css) {
+		c := css[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := skipCSSString(css, i)
+			out.WriteString(css[i:end])
+			i = end
+			continue
+
+		case c == '\\':
+			end := min(i+2, len(css))
+			out.WriteString(css[i:end])
+			i = end
+			continue
+
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end == -1 {
+				out.WriteString(css[i:])
+				return out.String()
+			}
+			out.WriteString(css[i : i+end+4])
+			i += end + 4
+			continue
+
+		case i > 0 && isIdentChar(css[i-1]):
+			// Only look for url( and @import at the start of a token
+
+		case hasPrefixFold(css[i:], "url("):
+			if src, end, ok := parseCSSURL(css, i+4); ok {
+				out.WriteString(`url("` + cssQuote(r.url(src)) + `")`)
+				i = end
+				continue
+			}
+
+		case hasPrefixFold(css[i:], "@import") && (i+7 == len(css) || !isIdentChar(css[i+7])):
+			end := scanCSS(css, i, ";")
+			if imported, ok := r.inline(css[i+7 : end]); ok {
+				out.WriteString(imported)
+				i = min(end+1, len(css))
+				continue
+			}
+		}
+
+		out.WriteByte(c)
+		i++
+	}
+	return out.String()
+}
+
+// url maps a url() reference to the URL used in the merged document
+func (r cssRewriter) url(src string) string {
+	if strings.HasPrefix(src, "#") && len(src) > 1 && r.anchor != nil {
+		return "#" + r.anchor(src[1:])
+	}
+	if resolved := r.a.resourceURL(src, r.baseDir); resolved != "" {
+		return resolved
+	}
+	return src
+}
+
+// inline returns the content of the stylesheet named by an @import prelude,
+// wrapped in @media when the import is conditional
+func (r cssRewriter) inline(prelude string) (string, bool) {
+	prelude = strings.TrimSpace(prelude)
+
+	var src string
+	var end int
+	switch {
+	case strings.HasPrefix(prelude, `"`), strings.HasPrefix(prelude, "'"):
+		end = skipCSSString(prelude, 0)
+		src = unquoteCSS(prelude[:end])
+	case hasPrefixFold(prelude, "url("):
+		var ok bool
+		if src, end, ok = parseCSSURL(prelude, 4); !ok {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	if schemeRegex.MatchString(src) {
+		return "", false
+	}
+	cssPath, _ := resolveHref(r.baseDir, src)
+	if r.seen[cssPath] {
+		// Already being imported further up: drop the cycle
+		return "", true
+	}
+	data, err := r.a.read(cssPath)
+	if err != nil {
+		return "", false
+	}
+
+	seen := map[string]bool{cssPath: true}
+	for p := range r.seen {
+		seen[p] = true
+	}
+	imported := cssRewriter{a: r.a, baseDir: path.Dir(cssPath), anchor: r.anchor, seen: seen}
+	content := imported.rewrite(string(data))
+
+	// Layer and supports() conditions are dropped; media queries are kept
+	media := strings.TrimSpace(prelude[end:])
+	if media != "" && !strings.EqualFold(media, "all") && !hasPrefixFold(media, "layer") && !hasPrefixFold(media, "supports(") {
+		content = "@media " + media + " {\n" + content + "\n}"
+	}
+	return content, true
+}
+
+// parseCSSURL parses the rest of a url( token starting at i, returning the
+// URL and the index after the closing parenthesis
+func parseCSSURL(css string, i int) (string, int, bool) {
+	for i < len(css) && strings.IndexByte(" \t\n\r\f", css[i]) != -1 {
+		i++
+	}
+	if i >= len(css) {
+		return "", 0, false
+	}
+
+	var src string
+	if css[i] == '"' || css[i] == '\'' {
+		end := skipCSSString(css, i)
+		src = unquoteCSS(css[i:end])
+		i = end
+	} else {
+		end := strings.IndexByte(css[i:], ')')
+		if end == -1 {
+			return "", 0, false
+		}
+		src = strings.TrimSpace(css[i : i+end])
+		i += end
+	}
+
+	for i < len(css) && strings.IndexByte(" \t\n\r\f", css[i]) != -1 {
+		i++
+	}
+	if i >= len(css) || css[i] != ')' {
+		return "", 0, false
+	}
+	return src, i + 1, true
+}
+
+// unquoteCSS strips the quotes of a CSS string and undoes simple escapes
+func unquoteCSS(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	} else if len(s) >= 1 {
+		s = s[1:]
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// cssQuote escapes a string (usually a URL) for use inside a double-quoted CSS string
+func cssQuote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\a `).Replace(s)
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,207 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testArchive returns an archive holding the given files
+func testArchive(t *testing.T, files map[string]string) *archive {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newArchive(r, nil)
+}
+
+func TestRewriteChapter(t *testing.T) {
+	a := testArchive(t, map[string]string{
+		"OEBPS/images/a.png":      "",
+		"OEBPS/images/b.png":      "",
+		"OEBPS/images/my pic.png": "",
+		"OEBPS/video/clip.jpg":    "",
+	})
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"img src", `<img src="../images/a.png" alt="A">`,
+			`<img src="epub/OEBPS/images/a.png" alt="A">`},
+		{"percent-encoded src", `<img src="../images/my%20pic.png">`,
+			`<img src="epub/OEBPS/images/my%20pic.png">`},
+		{"srcset", `<img srcset="../images/a.png 1x, ../images/b.png 2x">`,
+			`<img srcset="epub/OEBPS/images/a.png 1x, epub/OEBPS/images/b.png 2x">`},
+		{"picture sources", `<picture><source srcset="../images/b.png" media="(min-width: 5in)"><img src="../images/a.png"></picture>`,
+			`<picture><source srcset="epub/OEBPS/images/b.png" media="(min-width: 5in)"><img src="epub/OEBPS/images/a.png"></picture>`},
+		{"source src inside picture", `<picture><source src="../images/a.png"></picture>`,
+			`<picture><source src="epub/OEBPS/images/a.png"></picture>`},
+		{"media source outside picture", `<audio><source src="../images/a.png"></audio>`,
+			`<audio><source src="../images/a.png"></audio>`},
+		{"video poster", `<video poster="../video/clip.jpg"></video>`,
+			`<video poster="epub/OEBPS/video/clip.jpg"></video>`},
+		{"svg image", `<svg><image xlink:href="../images/a.png"/></svg>`,
+			`<svg><image xlink:href="epub/OEBPS/images/a.png"/></svg>`},
+		{"missing file kept", `<img src="../images/none.png">`, `<img src="../images/none.png">`},
+		{"remote image kept", `<img src="https://example.com/a.png">`, `<img src="https://example.com/a.png">`},
+		{"ids namespaced", `<p id="p1">x</p>`, `<p id="epub-ch1-p1">x</p>`},
+		{"self-closing element closed", `<a id="n1"/>text`, `<a id="epub-ch1-n1"></a>text`},
+		{"self-closing div closed", `<div class="x"/><p>after</p>`, `<div class="x"></div><p>after</p>`},
+		{"void element kept", `<br/>text`, `<br/>text`},
+		{"self-closing in svg kept", `<svg><circle r="1"/><rect/></svg>`, `<svg><circle r="1"/><rect/></svg>`},
+		{"link to another chapter", `<a href="ch2.xhtml#n1">2</a>`, `<a href="#epub-ch2-n1">2</a>`},
+		{"link to a chapter", `<a href="ch2.xhtml">2</a>`, `<a href="#epub-ch2">2</a>`},
+		{"link within the chapter", `<a href="#p1">1</a>`, `<a href="#epub-ch1-p1">1</a>`},
+		{"external link kept", `<a href="https://example.com/">x</a>`, `<a href="https://example.com/">x</a>`},
+		{"mail link kept", `<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com">x</a>`},
+		{"link outside the spine dropped", `<a class="note" href="notes.xhtml#n1">1</a>`, `<a class="note">1</a>`},
+		{"link to an image dropped", `<a href="../images/a.png" title="Full size">map</a>`, `<a title="Full size">map</a>`},
+		{"svg link outside the spine dropped", `<svg><a xlink:href="missing.xhtml"><text>x</text></a></svg>`,
+			`<svg><a><text>x</text></a></svg>`},
+		{"style attribute", `<div style="background: url(../images/a.png)">`,
+			`<div style="background: url(&#34;epub/OEBPS/images/a.png&#34;)">`},
+		{"style element", `<style>p { background: url('../images/a.png') }</style>`,
+			`<style>p { background: url("epub/OEBPS/images/a.png") }</style>`},
+		{"svg fill reference", `<svg><rect fill="url(#grad)"/></svg>`,
+			`<svg><rect fill="url(&#34;#epub-ch1-grad&#34;)"/></svg>`},
+		{"text and comments verbatim", `<p>a &amp; b<!-- <img src="../images/a.png"> --></p>`,
+			`<p>a &amp; b<!-- <img src="../images/a.png"> --></p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Book{archive: a, Chapters: []Chapter{
+				{Path: "OEBPS/text/ch1.xhtml", Order: 1, Content: tt.in},
+				{Path: "OEBPS/text/ch2.xhtml", Order: 2},
+			}}
+			b.rewriteChapter(&b.Chapters[0], a)
+			if got := b.Chapters[0].Content; got != tt.want {
+				t.Errorf("rewrite(%s)\n got %s\nwant %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteSrcset(t *testing.T) {
+	a := testArchive(t, map[string]string{
+		"img/a.png": "",
+		"img/b.png": "",
+	})
+	tests := []struct {
+		in, want string
+	}{
+		{"a.png", "epub/img/a.png"},
+		{"a.png 1x, b.png 2x", "epub/img/a.png 1x, epub/img/b.png 2x"},
+		{"a.png 480w,b.png 960w", "epub/img/a.png 480w, epub/img/b.png 960w"},
+		{"a.png, b.png 2x", "epub/img/a.png, epub/img/b.png 2x"},
+		{"  a.png\n 1x ,\n b.png   2x  ", "epub/img/a.png 1x, epub/img/b.png 2x"},
+		{"missing.png 1x, b.png 2x", "missing.png 1x, epub/img/b.png 2x"},
+		{"https://example.com/a.png 2x", "https://example.com/a.png 2x"},
+	}
+	for _, tt := range tests {
+		if got := a.rewriteSrcset(tt.in, "img"); got != tt.want {
+			t.Errorf("rewriteSrcset(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCSSRewriterURLs(t *testing.T) {
+	r := cssRewriter{
+		a: testArchive(t, map[string]string{
+			"OEBPS/fonts/f.woff":   "",
+			"OEBPS/images/a b.png": "",
+		}),
+		baseDir: "OEBPS/styles",
+		anchor:  func(id string) string { return namespacedID(1, id) },
+	}
+	tests := []struct {
+		name, in, want string
+	}{
+		{"unquoted", "src: url(../fonts/f.woff)", `src: url("epub/OEBPS/fonts/f.woff")`},
+		{"double quoted", `src: url("../fonts/f.woff")`, `src: url("epub/OEBPS/fonts/f.woff")`},
+		{"single quoted with spaces", "src: url( '../fonts/f.woff' )", `src: url("epub/OEBPS/fonts/f.woff")`},
+		{"uppercase", "src: URL(../fonts/f.woff)", `src: url("epub/OEBPS/fonts/f.woff")`},
+		{"query and fragment", "src: url(../fonts/f.woff?#iefix)", `src: url("epub/OEBPS/fonts/f.woff#iefix")`},
+		{"percent-encoded", "background: url(../images/a%20b.png)", `background: url("epub/OEBPS/images/a%20b.png")`},
+		{"fragment reference", "filter: url(#blur)", `filter: url("#epub-ch1-blur")`},
+		{"missing file kept", "src: url(none.woff)", `src: url("none.woff")`},
+		{"data URL kept", "src: url(data:font/woff;base64,AAAA)", `src: url("data:font/woff;base64,AAAA")`},
+		{"inside strings untouched", `content: "url(../fonts/f.woff)"`, `content: "url(../fonts/f.woff)"`},
+		{"inside comments untouched", "/* url(../fonts/f.woff) */", "/* url(../fonts/f.woff) */"},
+		{"only at token start", "background: myurl(../fonts/f.woff)", "background: myurl(../fonts/f.woff)"},
+		{"unterminated", "src: url(../fonts/f.woff", "src: url(../fonts/f.woff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.rewrite(tt.in); got != tt.want {
+				t.Errorf("rewrite(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSSRewriterImports(t *testing.T) {
+	a := testArchive(t, map[string]string{
+		"OEBPS/styles/base.css":      "p { margin: 0 }",
+		"OEBPS/styles/print.css":     "p { color: black }",
+		"OEBPS/styles/sub/fonts.css": "@font-face { src: url(../../fonts/f.woff) }",
+		"OEBPS/styles/cycle-a.css":   "@import 'cycle-b.css'; .a { x: 1 }",
+		"OEBPS/styles/cycle-b.css":   "@import 'cycle-a.css'; .b { x: 2 }",
+		"OEBPS/styles/self.css":      "@import 'self.css'; .self { x: 3 }",
+		"OEBPS/styles/nested.css":    "@import url(base.css); .nested { x: 4 }",
+		"OEBPS/fonts/f.woff":         "",
+		"OEBPS/styles/layered.css":   ".l { x: 5 }",
+	})
+	r := cssRewriter{a: a, baseDir: "OEBPS/styles"}
+	tests := []struct {
+		name, in, want string
+	}{
+		{"quoted", `@import "base.css";`, "p { margin: 0 }"},
+		{"url", "@import url(base.css);\nh1 { x: 1 }", "p { margin: 0 }\nh1 { x: 1 }"},
+		{"media query", "@import url('print.css') print;", "@media print {\np { color: black }\n}"},
+		{"media all", "@import 'base.css' all;", "p { margin: 0 }"},
+		{"layer dropped", "@import 'layered.css' layer(base);", ".l { x: 5 }"},
+		{"imported from a subdirectory", "@import 'sub/fonts.css';", `@font-face { src: url("epub/OEBPS/fonts/f.woff") }`},
+		{"nested imports", "@import 'nested.css';", "p { margin: 0 } .nested { x: 4 }"},
+		{"cycle broken", "@import 'cycle-a.css';", " .b { x: 2 } .a { x: 1 }"},
+		{"self import broken", "@import 'self.css';", " .self { x: 3 }"},
+		{"missing kept", "@import 'none.css';", "@import 'none.css';"},
+		{"remote kept", "@import url(https://example.com/a.css);", `@import url("https://example.com/a.css");`},
+		{"not an import", "@imports 'base.css';", "@imports 'base.css';"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.rewrite(tt.in); got != tt.want {
+				t.Errorf("rewrite(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteChapterStyleImport(t *testing.T) {
+	// @import in a chapter's <style> resolves against the chapter
+	a := testArchive(t, map[string]string{"OEBPS/styles/base.css": "p { margin: 0 }"})
+	b := &Book{archive: a, Chapters: []Chapter{{
+		Path:    "OEBPS/text/ch1.xhtml",
+		Order:   1,
+		Content: `<style>@import "../styles/base.css";</style>`,
+	}}}
+	b.rewriteChapter(&b.Chapters[0], a)
+	if got := b.Chapters[0].Content; !strings.Contains(got, "p { margin: 0 }") {
+		t.Errorf("@import in <style> not inlined: %s", got)
+	}
+}
@@ -0,0 +1,154 @@
+package epub
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ChapterFilter selects chapters. It is parsed from a comma-separated list
+// of chapter numbers and ranges as listed by "epub2pdf info" (3, 3-7, 12-),
+// regular expressions matched against chapter titles (/^Part/) and
+// chapter titles (Epilogue, case-insensitive). The zero value is empty.
+type ChapterFilter struct {
+	items []filterItem
+}
+
+type filterItem struct {
+	from, to int // Chapter numbers, to is 0 for open ranges
+	title    string
+	regex    *regexp.Regexp
+}
+
+var chapterRangeRegex = regexp.MustCompile(`^(\d+)(?:\s*-\s*(\d*))?$`)
+
+// ParseChapterFilter parses a chapter filter such as "3-7,12,/^Appendix/"
+func ParseChapterFilter(s string) (ChapterFilter, error) {
+	var f ChapterFilter
+	for _, item := range splitFilter(s) {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			continue
+		case len(item) >= 2 && item[0] == '/' && item[len(item)-1] == '/':
+			re, err := regexp.Compile("(?i)" + item[1:len(item)-1])
+			if err != nil {
+				return ChapterFilter{}, fmt.Errorf("invalid chapter pattern %s: %w", item, err)
+			}
+			f.items = append(f.items, filterItem{regex: re})
+		case chapterRangeRegex.MatchString(item):
+			m := chapterRangeRegex.FindStringSubmatch(item)
+			from, _ := strconv.Atoi(m[1])
+			to := from
+			if strings.Contains(item, "-") {
+				to, _ = strconv.Atoi(m[2]) // 0 when open-ended
+			}
+			if from < 1 || to != 0 && to < from {
+				return ChapterFilter{}, fmt.Errorf("invalid chapter range: %s", item)
+			}
+			f.items = append(f.items, filterItem{from: from, to: to})
+		default:
+			f.items = append(f.items, filterItem{title: item})
+		}
+	}
+	return f, nil
+}
+
+// splitFilter splits a filter on commas, except inside /regular expressions/
+func splitFilter(s string) []string {
+	var items []string
+	start := 0
+	inRegex := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inRegex:
+			i++
+		case s[i] == '/' && strings.TrimSpace(s[start:i]) == "":
+			inRegex = true
+		case s[i] == '/' && inRegex:
+			inRegex = false
+		case s[i] == ',' && !inRegex:
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// Empty reports whether the filter has no items
+func (f ChapterFilter) Empty() bool {
+	return len(f.items) == 0
+}
+
+// matches reports whether the chapter with the given one-based number
+// matches any item of the filter
+func (f ChapterFilter) matches(number int, chapter Chapter) bool {
+	for _, item := range f.items {
+		switch {
+		case item.regex != nil:
+			if item.regex.MatchString(chapter.Title) {
+				return true
+			}
+		case item.title != "":
+			if strings.EqualFold(item.title, strings.TrimSpace(chapter.Title)) {
+				return true
+			}
+		case number >= item.from && (item.to == 0 || number <= item.to):
+			return true
+		}
+	}
+	return false
+}
+
+// SelectChapters keeps the chapters matching include (all of them when it
+// is empty) and not matching exclude. TOC entries of dropped chapters are
+// removed, except as headings of entries that remain.
+func (b *Book) SelectChapters(include, exclude ChapterFilter) error {
+	var kept []Chapter
+	paths := make(map[string]bool)
+	for i, chapter := range b.Chapters {
+		if (include.Empty() || include.matches(i+1, chapter)) && !exclude.matches(i+1, chapter) {
+			kept = append(kept, chapter)
+			paths[chapter.Path] = true
+		}
+	}
+	if len(kept) == 0 {
+		return fmt.Errorf("no chapters match the selection")
+	}
+
+	b.Chapters = kept
+	b.TOC = pruneTOC(b.TOC, paths)
+	return nil
+}
+
+// Part returns a copy of the book holding the chapters from index from up
+// to (but not including) to, with the TOC pruned to match. The copy shares
+// the book's archive, so it is only usable while the book is open.
+func (b *Book) Part(from, to int) *Book {
+	part := *b
+	part.Chapters = b.Chapters[from:to:to]
+	paths := make(map[string]bool)
+	for _, chapter := range part.Chapters {
+		paths[chapter.Path] = true
+	}
+	part.TOC = pruneTOC(b.TOC, paths)
+	return &part
+}
+
+// pruneTOC drops the entries pointing at documents not in paths. Entries
+// with remaining children become headings without a target.
+func pruneTOC(entries []TOCEntry, paths map[string]bool) []TOCEntry {
+	var pruned []TOCEntry
+	for _, entry := range entries {
+		entry.Children = pruneTOC(entry.Children, paths)
+		if !paths[entry.Href] {
+			if len(entry.Children) == 0 {
+				continue
+			}
+			entry.Href, entry.Fragment = "", ""
+		}
+		pruned = append(pruned, entry)
+	}
+	return pruned
+}
@@ -0,0 +1,164 @@
+package epub
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChapterFilter(t *testing.T) {
+	tests := []struct {
+		in    string
+		items []filterItem
+		ok    bool
+	}{
+		{"", nil, true},
+		{" , ", nil, true},
+		{"3", []filterItem{{from: 3, to: 3}}, true},
+		{"3-7, 12", []filterItem{{from: 3, to: 7}, {from: 12, to: 12}}, true},
+		{"12 -", []filterItem{{from: 12}}, true},
+		{"Epilogue, Part 2", []filterItem{{title: "Epilogue"}, {title: "Part 2"}}, true},
+		{"0", nil, false},
+		{"7-3", nil, false},
+		{"/[/", nil, false},
+	}
+	for _, tt := range tests {
+		f, err := ParseChapterFilter(tt.in)
+		if (err == nil) != tt.ok || !reflect.DeepEqual(f.items, tt.items) {
+			t.Errorf("ParseChapterFilter(%q) = %+v, %v, want %+v (ok %t)", tt.in, f.items, err, tt.items, tt.ok)
+		}
+	}
+
+	// Commas inside a regular expression don't split it
+	f, err := ParseChapterFilter(`/^(One|Two),? /, 4, /a\/b,c/`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patterns []string
+	for _, item := range f.items {
+		if item.regex != nil {
+			patterns = append(patterns, item.regex.String())
+		}
+	}
+	if want := []string{"(?i)^(One|Two),? ", `(?i)a\/b,c`}; len(f.items) != 3 || !reflect.DeepEqual(patterns, want) {
+		t.Errorf("patterns = %q in %d items, want %q in 3", patterns, len(f.items), want)
+	}
+}
+
+// selectionBook has six chapters: a cover, two parts of two chapters each
+// and an epilogue, with a nested TOC
+func selectionBook() *Book {
+	return &Book{
+		Chapters: []Chapter{
+			{Title: "Cover", Path: "cover.xhtml"},
+			{Title: "Part One", Path: "p1.xhtml"},
+			{Title: "The Beginning", Path: "c1.xhtml"},
+			{Title: "Part Two", Path: "p2.xhtml"},
+			{Title: "The Middle", Path: "c2.xhtml"},
+			{Title: " Epilogue ", Path: "end.xhtml"},
+		},
+		TOC: []TOCEntry{
+			{Label: "Cover", Href: "cover.xhtml"},
+			{Label: "Part One", Href: "p1.xhtml", Children: []TOCEntry{
+				{Label: "The Beginning", Href: "c1.xhtml"},
+			}},
+			{Label: "Part Two", Href: "p2.xhtml", Children: []TOCEntry{
+				{Label: "The Middle", Href: "c2.xhtml", Fragment: "start"},
+			}},
+			{Label: "Epilogue", Href: "end.xhtml"},
+		},
+	}
+}
+
+func TestSelectChapters(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude string
+		want             []string // Paths of the chapters kept
+		err              bool
+	}{
+		{"everything", "", "", []string{"cover.xhtml", "p1.xhtml", "c1.xhtml", "p2.xhtml", "c2.xhtml", "end.xhtml"}, false},
+		{"range", "2-3", "", []string{"p1.xhtml", "c1.xhtml"}, false},
+		{"open range", "5-", "", []string{"c2.xhtml", "end.xhtml"}, false},
+		{"title ignores case and spaces", "epilogue", "", []string{"end.xhtml"}, false},
+		{"regex", "/^the /", "", []string{"c1.xhtml", "c2.xhtml"}, false},
+		{"exclude only", "", "1,/^part/", []string{"c1.xhtml", "c2.xhtml", "end.xhtml"}, false},
+		{"overlapping include and exclude", "2-5", "3-4", []string{"p1.xhtml", "c2.xhtml"}, false},
+		{"exclude wins", "Epilogue", "6", nil, true},
+		{"range past the end", "5-99", "", []string{"c2.xhtml", "end.xhtml"}, false},
+		{"numbers past the end are ignored", "1,40", "", []string{"cover.xhtml"}, false},
+		{"nothing in range", "7-9", "", nil, true},
+		{"unknown title", "Prologue", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			include, err := ParseChapterFilter(tt.include)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exclude, err := ParseChapterFilter(tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b := selectionBook()
+			err = b.SelectChapters(include, exclude)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("kept %d chapters, want an error", len(b.Chapters))
+				}
+				if len(b.Chapters) != 6 {
+					t.Errorf("a failed selection changed the book: %d chapters", len(b.Chapters))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			for _, chapter := range b.Chapters {
+				paths = append(paths, chapter.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("kept %q, want %q", paths, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneTOC(t *testing.T) {
+	toc := selectionBook().TOC
+	tests := []struct {
+		name  string
+		paths []string
+		want  []TOCEntry
+	}{
+		{"all", []string{"cover.xhtml", "p1.xhtml", "c1.xhtml", "p2.xhtml", "c2.xhtml", "end.xhtml"}, toc},
+		{"none", nil, nil},
+		{"leaves", []string{"cover.xhtml", "end.xhtml"}, []TOCEntry{
+			{Label: "Cover", Href: "cover.xhtml"},
+			{Label: "Epilogue", Href: "end.xhtml"},
+		}},
+		{"parent without its children", []string{"p1.xhtml"}, []TOCEntry{
+			{Label: "Part One", Href: "p1.xhtml"},
+		}},
+		{"child keeps its parent as a heading", []string{"c2.xhtml"}, []TOCEntry{
+			{Label: "Part Two", Children: []TOCEntry{
+				{Label: "The Middle", Href: "c2.xhtml", Fragment: "start"},
+			}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := make(map[string]bool)
+			for _, p := range tt.paths {
+				paths[p] = true
+			}
+			if got := pruneTOC(toc, paths); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pruneTOC = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if !reflect.DeepEqual(toc, selectionBook().TOC) {
+		t.Error("pruneTOC changed the TOC it was given")
+	}
+}
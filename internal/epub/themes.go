@@ -0,0 +1,112 @@
+package epub
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Theme is a set of typography overrides applied on top of the book's own
+// styles. Empty fields keep the book's choice.
+type Theme struct {
+	FontFamily string  // CSS font-family list
+	FontSize   string  // CSS length such as 12pt
+	LineHeight float64 // Multiple of the font size
+	CSS        string  // Extra rules
+}
+
+// Themes are the built-in typography themes
+var Themes = map[string]Theme{
+	"serif": {
+		FontFamily: "Georgia, 'Times New Roman', serif",
+		LineHeight: 1.6,
+	},
+	"sans": {
+		FontFamily: "'Helvetica Neue', Arial, sans-serif",
+		LineHeight: 1.5,
+	},
+	"large-print": {
+		FontFamily: "Verdana, Arial, sans-serif",
+		FontSize:   "16pt",
+		LineHeight: 1.5,
+		CSS:        ".book p { text-align: left !important; hyphens: none; }",
+	},
+	"dyslexia-friendly": {
+		FontFamily: "OpenDyslexic, Lexend, Verdana, Arial, sans-serif",
+		FontSize:   "13pt",
+		LineHeight: 1.8,
+		CSS: ".book .chapter { letter-spacing: 0.05em; word-spacing: 0.16em; }\n" +
+			".book p { text-align: left !important; hyphens: none; }",
+	},
+	"e-ink": {
+		FontFamily: "Georgia, 'Times New Roman', serif",
+		FontSize:   "12pt",
+		LineHeight: 1.5,
+		CSS: "body, .book .chapter, .book .chapter * { color: #000 !important; background-color: transparent !important; }\n" +
+			".book a { text-decoration: underline; }",
+	},
+}
+
+// ThemeNames returns the names of the built-in themes
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeText lists the elements whose font family and line height follow
+// the theme rather than the book. Code keeps its monospace font.
+const themeText = "p, li, dd, dt, blockquote, figcaption, td, th, div, span, a, em, strong, i, b, h1, h2, h3, h4, h5, h6"
+
+// writeThemeCSS writes the theme and the explicit typography options, which
+// override the theme's. They come after the book's stylesheets and win over
+// them, as a reading system's font settings do.
+func writeThemeCSS(sb *strings.Builder, opts HTMLOptions) {
+	theme := Themes[opts.Theme]
+	if opts.FontFamily != "" {
+		theme.FontFamily = opts.FontFamily
+	}
+	if opts.FontSize != "" {
+		theme.FontSize = opts.FontSize
+	}
+	if opts.LineHeight > 0 {
+		theme.LineHeight = opts.LineHeight
+	}
+
+	var wrapper, text []string
+	if theme.FontFamily != "" {
+		wrapper = append(wrapper, fmt.Sprintf("font-family: %s !important;", theme.FontFamily))
+		text = append(text, "font-family: inherit !important;")
+	}
+	if theme.FontSize != "" {
+		// Text sized relative to the chapter scales with it
+		wrapper = append(wrapper, fmt.Sprintf("font-size: %s !important;", theme.FontSize))
+	}
+	if theme.LineHeight > 0 {
+		wrapper = append(wrapper, fmt.Sprintf("line-height: %g !important;", theme.LineHeight))
+		text = append(text, "line-height: inherit !important;")
+	}
+
+	if len(wrapper) > 0 {
+		sb.WriteString(fmt.Sprintf(".book, .book .chapter { %s }\n", strings.Join(wrapper, " ")))
+	}
+	if len(text) > 0 {
+		sb.WriteString(fmt.Sprintf(".book .chapter :is(%s) { %s }\n", themeText, strings.Join(text, " ")))
+	}
+	if theme.CSS != "" {
+		sb.WriteString(theme.CSS + "\n")
+	}
+}
+
+// writeUserCSS writes the user's stylesheets, last so they win over
+// everything else. "</" is escaped so a stylesheet can't close the
+// <style> element.
+func writeUserCSS(sb *strings.Builder, opts HTMLOptions) {
+	for _, css := range opts.UserCSS {
+		sb.WriteString(strings.ReplaceAll(css, "</", `<\/`))
+		sb.WriteString("\n")
+	}
+}
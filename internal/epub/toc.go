@@ -0,0 +1,273 @@
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// TOCEntry represents a single entry in the book's table of contents
+type TOCEntry struct {
+	Label    string     // Human readable label
+	Href     string     // Target document path inside the EPUB archive
+	Fragment string     // Target anchor inside the document (may be empty)
+	Children []TOCEntry // Nested entries
+}
+
+// navDoc mirrors the <nav epub:type="toc"> element of an EPUB 3 nav document
+type navDoc struct {
+	List navList `xml:"ol"`
+}
+
+type navList struct {
+	Items []navItem `xml:"li"`
+}
+
+type navItem struct {
+	Link navLabel `xml:"a"`
+	Span navLabel `xml:"span"`
+	List *navList `xml:"ol"`
+}
+
+type navLabel struct {
+	Href  string `xml:"href,attr"`
+	Inner string `xml:",innerxml"`
+}
+
+// ncxDoc mirrors the EPUB 2 NCX document
+type ncxDoc struct {
+	XMLName xml.Name   `xml:"ncx"`
+	Points  []navPoint `xml:"navMap>navPoint"`
+}
+
+type navPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Points []navPoint `xml:"navPoint"`
+}
+
+var tagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// parseTOC builds the table of contents from the EPUB 3 nav document,
+// falling back to the EPUB 2 NCX referenced by the spine
+func parseTOC(pkg *Package, basePath string, files map[string]*zip.File) []TOCEntry {
+	for _, item := range pkg.Manifest.Items {
+		if !hasProperty(item.Properties, "nav") {
+			continue
+		}
+		navPath := resolvePath(basePath, item.Href)
+		if f, ok := files[navPath]; ok {
+			if toc, err := parseNav(f, path.Dir(navPath)); err == nil && len(toc) > 0 {
+				return toc
+			}
+		}
+	}
+
+	ncxID := pkg.Spine.Toc
+	for _, item := range pkg.Manifest.Items {
+		if item.ID != ncxID && !(ncxID == "" && item.MediaType == "application/x-dtbncx+xml") {
+			continue
+		}
+		ncxPath := resolvePath(basePath, item.Href)
+		if f, ok := files[ncxPath]; ok {
+			if toc, err := parseNCX(f, path.Dir(ncxPath)); err == nil {
+				return toc
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseNav(f *zip.File, baseDir string) ([]TOCEntry, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(rc)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("toc nav not found: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "nav" || !isTOCNav(start) {
+			continue
+		}
+
+		var nav navDoc
+		if err := decoder.DecodeElement(&nav, &start); err != nil {
+			return nil, fmt.Errorf("failed to parse nav document: %w", err)
+		}
+		return navEntries(nav.List, baseDir), nil
+	}
+}
+
+func isTOCNav(start xml.StartElement) bool {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "type" && hasProperty(attr.Value, "toc") {
+			return true
+		}
+	}
+	return false
+}
+
+func navEntries(list navList, baseDir string) []TOCEntry {
+	var entries []TOCEntry
+	for _, li := range list.Items {
+		label := li.Link
+		if label.Href == "" && label.Inner == "" {
+			label = li.Span
+		}
+
+		entry := TOCEntry{Label: cleanLabel(label.Inner)}
+		entry.Href, entry.Fragment = resolveHref(baseDir, label.Href)
+		if li.List != nil {
+			entry.Children = navEntries(*li.List, baseDir)
+		}
+
+		// Skip empty list items but keep their children
+		if entry.Label == "" && entry.Href == "" {
+			entries = append(entries, entry.Children...)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func parseNCX(f *zip.File, baseDir string) ([]TOCEntry, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(rc)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var doc ncxDoc
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse NCX: %w", err)
+	}
+	return ncxEntries(doc.Points, baseDir), nil
+}
+
+func ncxEntries(points []navPoint, baseDir string) []TOCEntry {
+	var entries []TOCEntry
+	for _, p := range points {
+		entry := TOCEntry{
+			Label:    cleanLabel(p.Label),
+			Children: ncxEntries(p.Points, baseDir),
+		}
+		entry.Href, entry.Fragment = resolveHref(baseDir, p.Content.Src)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// resolveHref resolves a document-relative href into an archive path and fragment
+func resolveHref(baseDir, href string) (string, string) {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return "", ""
+	}
+
+	fragment := ""
+	if idx := strings.Index(href, "#"); idx != -1 {
+		fragment = href[idx+1:]
+		href = href[:idx]
+	}
+	if decoded, err := url.PathUnescape(href); err == nil {
+		href = decoded
+	}
+	if decoded, err := url.PathUnescape(fragment); err == nil {
+		fragment = decoded
+	}
+	if href == "" {
+		return "", fragment
+	}
+
+	return normalizePath(resolveRelativePath(baseDir, href)), fragment
+}
+
+func cleanLabel(s string) string {
+	s = tagRegex.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func hasProperty(properties, name string) bool {
+	for _, p := range strings.Fields(properties) {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WalkTOC visits every entry depth-first, passing its nesting depth (starting at 0)
+func WalkTOC(entries []TOCEntry, fn func(entry TOCEntry, depth int)) {
+	var walk func([]TOCEntry, int)
+	walk = func(list []TOCEntry, depth int) {
+		for _, e := range list {
+			fn(e, depth)
+			walk(e.Children, depth+1)
+		}
+	}
+	walk(entries, 0)
+}
+
+// chapterTitles maps each document path to the label of the first TOC entry
+// that targets it, preferring entries pointing at the top of the document
+func chapterTitles(toc []TOCEntry) map[string]string {
+	titles := make(map[string]string)
+	anchored := make(map[string]string)
+	WalkTOC(toc, func(e TOCEntry, depth int) {
+		if e.Href == "" || e.Label == "" {
+			return
+		}
+		if e.Fragment == "" {
+			if _, ok := titles[e.Href]; !ok {
+				titles[e.Href] = e.Label
+			}
+		} else if _, ok := anchored[e.Href]; !ok {
+			anchored[e.Href] = e.Label
+		}
+	})
+	for href, label := range anchored {
+		if _, ok := titles[href]; !ok {
+			titles[href] = label
+		}
+	}
+	return titles
+}
+
+// tocAnchors returns the distinct anchors targeted by the TOC, in order
+func (b *Book) tocAnchors() []string {
+	var anchors []string
+	seen := make(map[string]bool)
+	WalkTOC(b.TOC, func(e TOCEntry, depth int) {
+		for _, anchor := range []string{b.Anchor(e.Href, ""), b.Anchor(e.Href, e.Fragment)} {
+			if anchor != "" && !seen[anchor] {
+				seen[anchor] = true
+				anchors = append(anchors, anchor)
+			}
+		}
+	})
+	return anchors
+}
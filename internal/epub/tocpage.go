@@ -0,0 +1,104 @@
+package epub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tocPageCSS styles the printed table of contents
+const tocPageCSS = `
+		.toc-page {
+			break-before: page;
+			break-after: page;
+		}
+		.toc-page ol {
+			list-style: none;
+			margin: 0;
+			padding-left: 1.5em;
+		}
+		.toc-page > ol {
+			padding-left: 0;
+		}
+		.toc-page li {
+			margin: 0.3em 0;
+		}
+		.toc-page a, .toc-page .toc-heading {
+			display: flex;
+			align-items: baseline;
+			color: inherit;
+			text-decoration: none;
+		}
+		.toc-page .toc-leader {
+			flex: 1;
+			margin: 0 0.3em;
+			border-bottom: 1px dotted currentColor;
+		}
+		.toc-page .toc-number {
+			flex: none;
+			min-width: 2.5em;
+			text-align: right;
+		}
+	`
+
+// writeTOCPage writes the printed table of contents from the book's
+// navigation. Page numbers come from a previous render (opts.PageNumbers);
+// without them the numbers are left blank but keep their space, so the
+// pages don't move once they are filled in.
+func (b *Book) writeTOCPage(sb *strings.Builder, opts HTMLOptions) {
+	if len(b.TOC) == 0 {
+		return
+	}
+	sb.WriteString("<nav class=\"toc-page\">\n<h1>Contents</h1>\n")
+	b.writeTOCList(sb, b.TOC, opts.PageNumbers)
+	sb.WriteString("</nav>\n")
+}
+
+func (b *Book) writeTOCList(sb *strings.Builder, entries []TOCEntry, pages map[string]int) {
+	sb.WriteString("<ol>\n")
+	for _, entry := range entries {
+		if entry.Label == "" {
+			// Nothing to show: promote the children
+			if len(entry.Children) > 0 {
+				sb.WriteString("<li>")
+				b.writeTOCList(sb, entry.Children, pages)
+				sb.WriteString("</li>\n")
+			}
+			continue
+		}
+
+		sb.WriteString("<li>")
+		label := fmt.Sprintf("<span class=\"toc-label\">%s</span>", escapeHTML(entry.Label))
+		if anchor := b.TOCAnchor(entry); anchor != "" {
+			number := ""
+			if page, ok := pages[anchor]; ok {
+				number = fmt.Sprint(page)
+			}
+			sb.WriteString(fmt.Sprintf("<a href=\"#%s\">%s<span class=\"toc-leader\"></span><span class=\"toc-number\">%s</span></a>",
+				escapeHTML(anchor), label, number))
+		} else {
+			sb.WriteString(fmt.Sprintf("<span class=\"toc-heading\">%s</span>", label))
+		}
+		if len(entry.Children) > 0 {
+			sb.WriteString("\n")
+			b.writeTOCList(sb, entry.Children, pages)
+		}
+		sb.WriteString("</li>\n")
+	}
+	sb.WriteString("</ol>\n")
+}
+
+// TOCAnchor returns the anchor in the merged document a TOC entry points
+// at. Fragments that don't exist in their chapter fall back to the
+// chapter's own anchor.
+func (b *Book) TOCAnchor(entry TOCEntry) string {
+	for _, chapter := range b.Chapters {
+		if chapter.Path != entry.Href {
+			continue
+		}
+		if entry.Fragment != "" && chapter.ids[entry.Fragment] {
+			return namespacedID(chapter.Order, entry.Fragment)
+		}
+		return ChapterAnchor(chapter.Order)
+	}
+	return ""
+}
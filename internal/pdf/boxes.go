@@ -0,0 +1,128 @@
+package pdf
+
+import (
+	"fmt"
+)
+
+// Rect is a PDF rectangle [llx lly urx ury] in points
+type Rect [4]float64
+
+// Width returns the width of the rectangle
+func (r Rect) Width() float64 { return r[2] - r[0] }
+
+// Height returns the height of the rectangle
+func (r Rect) Height() float64 { return r[3] - r[1] }
+
+// Inset returns the rectangle shrunk by d on every side
+func (r Rect) Inset(d float64) Rect {
+	return Rect{r[0] + d, r[1] + d, r[2] - d, r[3] - d}
+}
+
+func (r Rect) array() Array {
+	return Array{r[0], r[1], r[2], r[3]}
+}
+
+// MediaBox returns the media box of a page, which may be inherited from an
+// ancestor in the page tree
+func (d *Document) MediaBox(page Ref) (Rect, error) {
+	var obj Object = page
+	for depth := 0; depth < 32; depth++ {
+		node, err := d.ResolveDict(obj)
+		if err != nil {
+			return Rect{}, err
+		}
+		if node == nil {
+			break
+		}
+		if box, ok := node["MediaBox"]; ok {
+			return d.rect(box)
+		}
+		obj = node["Parent"]
+	}
+	return Rect{}, fmt.Errorf("pdf: page %d has no media box", page.Num)
+}
+
+func (d *Document) rect(obj Object) (Rect, error) {
+	resolved, err := d.Resolve(obj)
+	if err != nil {
+		return Rect{}, err
+	}
+	arr, ok := resolved.(Array)
+	if !ok || len(arr) != 4 {
+		return Rect{}, fmt.Errorf("pdf: invalid rectangle")
+	}
+	var r Rect
+	for i, item := range arr {
+		item, err := d.Resolve(item)
+		if err != nil {
+			return Rect{}, err
+		}
+		switch v := item.(type) {
+		case int64:
+			r[i] = float64(v)
+		case float64:
+			r[i] = v
+		default:
+			return Rect{}, fmt.Errorf("pdf: invalid rectangle")
+		}
+	}
+	return r, nil
+}
+
+// SetPageBoxes sets page boundaries such as /TrimBox and /BleedBox on a page
+func (d *Document) SetPageBoxes(page Ref, boxes map[Name]Rect) error {
+	node, err := d.ResolveDict(page)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("pdf: page %d is missing", page.Num)
+	}
+
+	node = node.Copy()
+	for name, box := range boxes {
+		node[name] = box.array()
+	}
+	d.Set(page, node)
+	return nil
+}
+
+// AppendContent draws content on top of a page. The page's own content is
+// wrapped in q/Q so it can't leave the graphics state changed.
+func (d *Document) AppendContent(page Ref, content []byte) error {
+	node, err := d.ResolveDict(page)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("pdf: page %d is missing", page.Num)
+	}
+
+	var contents Array
+	switch v := node["Contents"].(type) {
+	case nil:
+	case Ref:
+		// The reference may be to a stream or to an array of streams
+		resolved, err := d.Resolve(v)
+		if err != nil {
+			return err
+		}
+		if arr, ok := resolved.(Array); ok {
+			contents = append(contents, arr...)
+		} else {
+			contents = append(contents, v)
+		}
+	case Array:
+		contents = append(contents, v...)
+	default:
+		return fmt.Errorf("pdf: page %d has invalid contents", page.Num)
+	}
+
+	save := d.Add(&Stream{Dict: Dict{}, Data: []byte("q")})
+	restore := d.Add(&Stream{Dict: Dict{}, Data: append([]byte("Q\n"), content...)})
+
+	node = node.Copy()
+	node["Contents"] = append(append(Array{save}, contents...), restore)
+	d.Set(page, node)
+	return nil
+}
@@ -0,0 +1,312 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ErrUnsupported is returned for valid PDF features this package does not
+// implement, such as cross-reference streams
+var ErrUnsupported = errors.New("pdf: unsupported document structure")
+
+// Document is a parsed PDF file that can be extended with an incremental update
+type Document struct {
+	data    []byte
+	offsets map[int]int64 // object number -> byte offset of "n g obj"
+	Trailer Dict
+	size    int
+	updates map[int]Object
+	cache   map[int]Object
+}
+
+// Open parses the cross-reference table and trailer of a PDF file
+func Open(data []byte) (*Document, error) {
+	d := &Document{
+		data:    data,
+		offsets: make(map[int]int64),
+		updates: make(map[int]Object),
+		cache:   make(map[int]Object),
+	}
+
+	idx := bytes.LastIndex(data, []byte("startxref"))
+	if idx == -1 {
+		return nil, fmt.Errorf("pdf: startxref not found")
+	}
+	p := &parser{data: data, pos: idx + len("startxref")}
+	offset, err := strconv.ParseInt(p.keyword(), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: invalid startxref: %w", err)
+	}
+
+	seen := make(map[int64]bool)
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+		trailer, err := d.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if d.Trailer == nil {
+			d.Trailer = trailer
+		}
+		prev, ok := trailer["Prev"].(int64)
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+
+	if size, ok := d.Trailer["Size"].(int64); ok {
+		d.size = int(size)
+	}
+	if _, ok := d.Trailer["Root"].(Ref); !ok {
+		return nil, fmt.Errorf("pdf: trailer has no /Root")
+	}
+
+	return d, nil
+}
+
+// readXref reads one classic cross-reference section and its trailer.
+// Entries already known from a newer section are kept.
+func (d *Document) readXref(offset int64) (Dict, error) {
+	if offset < 0 || offset >= int64(len(d.data)) {
+		return nil, fmt.Errorf("pdf: xref offset %d out of range", offset)
+	}
+	p := &parser{data: d.data, pos: int(offset)}
+	if p.keyword() != "xref" {
+		return nil, ErrUnsupported
+	}
+
+	for {
+		word := p.keyword()
+		if word == "trailer" {
+			break
+		}
+		start, err := strconv.Atoi(word)
+		if err != nil {
+			return nil, p.errorf("invalid xref subsection %q", word)
+		}
+		count, err := strconv.Atoi(p.keyword())
+		if err != nil {
+			return nil, p.errorf("invalid xref subsection count")
+		}
+
+		for i := 0; i < count; i++ {
+			off, err1 := strconv.ParseInt(p.keyword(), 10, 64)
+			_, err2 := strconv.Atoi(p.keyword())
+			kind := p.keyword()
+			if err1 != nil || err2 != nil {
+				return nil, p.errorf("invalid xref entry")
+			}
+			num := start + i
+			if _, known := d.offsets[num]; known {
+				continue
+			}
+			if kind == "n" {
+				d.offsets[num] = off
+			} else {
+				d.offsets[num] = -1 // free
+			}
+		}
+	}
+
+	obj, err := p.object()
+	if err != nil {
+		return nil, err
+	}
+	trailer, ok := obj.(Dict)
+	if !ok {
+		return nil, fmt.Errorf("pdf: invalid trailer")
+	}
+	return trailer, nil
+}
+
+// Object returns the object with the given reference, including pending updates
+func (d *Document) Object(ref Ref) (Object, error) {
+	if obj, ok := d.updates[ref.Num]; ok {
+		return obj, nil
+	}
+	if obj, ok := d.cache[ref.Num]; ok {
+		return obj, nil
+	}
+
+	offset, ok := d.offsets[ref.Num]
+	if !ok || offset < 0 {
+		return nil, nil
+	}
+	if offset >= int64(len(d.data)) {
+		return nil, fmt.Errorf("pdf: object %d offset out of range", ref.Num)
+	}
+
+	p := &parser{data: d.data, pos: int(offset)}
+	if _, err := strconv.Atoi(p.keyword()); err != nil {
+		return nil, p.errorf("expected object number")
+	}
+	p.keyword() // generation
+	if p.keyword() != "obj" {
+		return nil, p.errorf("expected obj keyword")
+	}
+
+	obj, err := p.object()
+	if err != nil {
+		return nil, err
+	}
+
+	if dict, ok := obj.(Dict); ok {
+		save := p.pos
+		if p.keyword() == "stream" {
+			obj, err = d.readStream(p, dict)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			p.pos = save
+		}
+	}
+
+	d.cache[ref.Num] = obj
+	return obj, nil
+}
+
+func (d *Document) readStream(p *parser, dict Dict) (*Stream, error) {
+	// The keyword is followed by CRLF or LF
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+
+	lengthObj, err := d.Resolve(dict["Length"])
+	if err != nil {
+		return nil, err
+	}
+	length, ok := lengthObj.(int64)
+	if !ok || length < 0 || p.pos+int(length) > len(p.data) {
+		return nil, p.errorf("invalid stream length")
+	}
+
+	data := d.data[p.pos : p.pos+int(length)]
+	return &Stream{Dict: dict, Data: data}, nil
+}
+
+// Resolve follows indirect references until a direct object is reached
+func (d *Document) Resolve(obj Object) (Object, error) {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(Ref)
+		if !ok {
+			return obj, nil
+		}
+		var err error
+		obj, err = d.Object(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("pdf: reference chain too long")
+}
+
+// ResolveDict resolves obj and returns it as a dictionary (nil if it is not one)
+func (d *Document) ResolveDict(obj Object) (Dict, error) {
+	obj, err := d.Resolve(obj)
+	if err != nil {
+		return nil, err
+	}
+	switch v := obj.(type) {
+	case Dict:
+		return v, nil
+	case *Stream:
+		return v.Dict, nil
+	}
+	return nil, nil
+}
+
+// Catalog returns the document catalog and its reference
+func (d *Document) Catalog() (Dict, Ref, error) {
+	ref := d.Trailer["Root"].(Ref)
+	catalog, err := d.ResolveDict(ref)
+	if err != nil {
+		return nil, ref, err
+	}
+	if catalog == nil {
+		return nil, ref, fmt.Errorf("pdf: catalog not found")
+	}
+	return catalog, ref, nil
+}
+
+// Add stores a new indirect object in the pending update and returns its reference
+func (d *Document) Add(obj Object) Ref {
+	ref := Ref{Num: d.size}
+	d.size++
+	d.updates[ref.Num] = obj
+	return ref
+}
+
+// Set replaces an existing indirect object in the pending update
+func (d *Document) Set(ref Ref, obj Object) {
+	d.updates[ref.Num] = obj
+	if ref.Num >= d.size {
+		d.size = ref.Num + 1
+	}
+}
+
+// Bytes returns the original file followed by an incremental update holding
+// every object passed to Add or Set
+func (d *Document) Bytes() []byte {
+	if len(d.updates) == 0 {
+		return d.data
+	}
+
+	var buf bytes.Buffer
+	buf.Write(d.data)
+	if len(d.data) > 0 && d.data[len(d.data)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(d.updates))
+	for num := range d.updates {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	offsets := make(map[int]int, len(nums))
+	for _, num := range nums {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", num)
+		writeObject(&buf, d.updates[num])
+		buf.WriteString("\nendobj\n")
+	}
+
+	xrefOffset := buf.Len()
+	buf.WriteString("xref\n")
+	for i := 0; i < len(nums); {
+		j := i
+		for j+1 < len(nums) && nums[j+1] == nums[j]+1 {
+			j++
+		}
+		fmt.Fprintf(&buf, "%d %d\n", nums[i], j-i+1)
+		for k := i; k <= j; k++ {
+			fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[nums[k]])
+		}
+		i = j + 1
+	}
+
+	trailer := d.Trailer.Copy()
+	delete(trailer, "XRefStm")
+	trailer["Size"] = int64(d.size)
+	trailer["Prev"] = d.lastXref()
+	buf.WriteString("trailer\n")
+	writeObject(&buf, trailer)
+	fmt.Fprintf(&buf, "\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	return buf.Bytes()
+}
+
+func (d *Document) lastXref() int64 {
+	idx := bytes.LastIndex(d.data, []byte("startxref"))
+	p := &parser{data: d.data, pos: idx + len("startxref")}
+	offset, _ := strconv.ParseInt(p.keyword(), 10, 64)
+	return offset
+}
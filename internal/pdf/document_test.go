@@ -0,0 +1,293 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// buildPDF writes a PDF with a classic xref table holding objects 1..n,
+// given as their bodies, and returns it with the offset of its xref
+func buildPDF(objects []string, trailer string) ([]byte, int) {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+	return buf.Bytes(), xref
+}
+
+// twoPages is a minimal document: catalog, page tree, two pages and a
+// content stream
+func twoPages() ([]byte, int) {
+	return buildPDF([]string{
+		"<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 612 792]>>",
+		"<</Type /Page /Parent 2 0 R /Contents 5 0 R>>",
+		"<</Type /Page /Parent 2 0 R>>",
+		"<</Length 8>>\nstream\n0 0 m S\n\nendstream",
+	}, "<</Size 6 /Root 1 0 R>>")
+}
+
+func TestOpen(t *testing.T) {
+	data, _ := twoPages()
+	doc, err := Open(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := doc.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Ref{{Num: 3}, {Num: 4}}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("Pages() = %v, want %v", pages, want)
+	}
+
+	box, err := doc.MediaBox(pages[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Rect{0, 0, 612, 792}); box != want {
+		t.Errorf("inherited MediaBox = %v, want %v", box, want)
+	}
+
+	obj, err := doc.Object(Ref{Num: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, ok := obj.(*Stream)
+	if !ok || string(stream.Data) != "0 0 m S\n" {
+		t.Errorf("Object(5) = %#v, want the content stream", obj)
+	}
+
+	if obj, err := doc.Object(Ref{Num: 0}); obj != nil || err != nil {
+		t.Errorf("free object = %#v, %v, want nil", obj, err)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	valid, xref := twoPages()
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"no startxref", []byte("%PDF-1.4\n"), nil},
+		{"invalid startxref", bytes.Replace(valid, []byte("startxref\n"+strconv.Itoa(xref)), []byte("startxref\nabc"), 1), nil},
+		{"startxref out of range", bytes.Replace(valid, []byte("startxref\n"+strconv.Itoa(xref)), []byte("startxref\n999999"), 1), nil},
+		{"xref stream", bytes.Replace(valid, []byte("xref\n0 6"), []byte("6 0 obj"), 1), ErrUnsupported},
+		{"bad entry", bytes.Replace(valid, []byte("0000000000 65535 f"), []byte("00000000x0 65535 f"), 1), nil},
+		{"no root", bytes.Replace(valid, []byte("/Root 1 0 R"), []byte("/Info 1 0 R"), 1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(tt.data)
+			if err == nil {
+				t.Fatal("Open succeeded, want an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Open error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadXrefSubsections(t *testing.T) {
+	data, xref := twoPages()
+
+	// Split the table into two subsections and mark object 4 free
+	table := string(data[xref:])
+	lines := strings.Split(table, "\n")
+	// lines: xref, "0 6", 0..5 entries, trailer...
+	entries := lines[2:8]
+	entries[4] = "0000000000 00001 f "
+	rebuilt := append([]string{"xref", "0 3"}, entries[:3]...)
+	rebuilt = append(rebuilt, "3 3")
+	rebuilt = append(rebuilt, entries[3:]...)
+	rebuilt = append(rebuilt, lines[8:]...)
+	data = append(data[:xref:xref], strings.Join(rebuilt, "\n")...)
+
+	doc, err := Open(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj, _ := doc.Object(Ref{Num: 3}); obj == nil {
+		t.Error("object 3 of the second subsection not found")
+	}
+	if obj, _ := doc.Object(Ref{Num: 4}); obj != nil {
+		t.Errorf("free object 4 = %#v, want nil", obj)
+	}
+}
+
+// checkXref verifies that every in-use entry of each xref section in the
+// chain points at its "n 0 obj" header, and returns the sections' offsets,
+// newest first
+func checkXref(t *testing.T, data []byte) []int64 {
+	t.Helper()
+	idx := bytes.LastIndex(data, []byte("startxref"))
+	p := &parser{data: data, pos: idx + len("startxref")}
+	offset, err := strconv.ParseInt(p.keyword(), 10, 64)
+	if err != nil {
+		t.Fatalf("startxref: %v", err)
+	}
+
+	var chain []int64
+	for offset > 0 {
+		chain = append(chain, offset)
+		p := &parser{data: data, pos: int(offset)}
+		if p.keyword() != "xref" {
+			t.Fatalf("no xref at offset %d", offset)
+		}
+		for {
+			word := p.keyword()
+			if word == "trailer" {
+				break
+			}
+			start, _ := strconv.Atoi(word)
+			count, _ := strconv.Atoi(p.keyword())
+			for i := 0; i < count; i++ {
+				off, _ := strconv.Atoi(p.keyword())
+				p.keyword()
+				if p.keyword() != "n" {
+					continue
+				}
+				header := fmt.Sprintf("%d 0 obj", start+i)
+				if !bytes.HasPrefix(data[off:], []byte(header)) {
+					t.Errorf("xref at %d: object %d points at %q", offset, start+i, data[off:min(off+20, len(data))])
+				}
+			}
+		}
+		trailer, err := p.object()
+		if err != nil {
+			t.Fatalf("trailer at %d: %v", offset, err)
+		}
+		prev, ok := trailer.(Dict)["Prev"].(int64)
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+	return chain
+}
+
+func TestIncrementalUpdateRoundTrip(t *testing.T) {
+	data, originalXref := twoPages()
+	doc, err := Open(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := doc.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest := Array{pages[1], Name("XYZ"), nil, nil, nil}
+	if err := doc.SetOutline([]OutlineItem{
+		{Title: "Part One", Dest: Array{pages[0], Name("Fit")}, Children: []OutlineItem{
+			{Title: "Chapitre é", Dest: dest},
+		}},
+		{Title: "Part Two", Dest: dest},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetInfo(Info{Title: "A Book", Author: "Someone"}); err != nil {
+		t.Fatal(err)
+	}
+	trim := Rect{9, 9, 603, 783}
+	if err := doc.SetPageBoxes(pages[0], map[Name]Rect{"TrimBox": trim}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := doc.Bytes()
+	if !bytes.HasPrefix(out, data) {
+		t.Fatal("the update doesn't start with the original file")
+	}
+	chain := checkXref(t, out)
+	if len(chain) != 2 || chain[1] != int64(originalXref) {
+		t.Errorf("xref chain = %v, want the update then %d", chain, originalXref)
+	}
+
+	// Read everything back from the updated file
+	doc, err = Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := doc.Trailer["Size"].(int64); size != int64(doc.size) || size <= 6 {
+		t.Errorf("trailer /Size = %d", size)
+	}
+	if got, _ := doc.Pages(); !reflect.DeepEqual(got, pages) {
+		t.Errorf("Pages() after update = %v, want %v", got, pages)
+	}
+
+	catalog, _, err := doc.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if catalog["PageMode"] != Name("UseOutlines") {
+		t.Errorf("catalog /PageMode = %v", catalog["PageMode"])
+	}
+	outlines, _ := doc.ResolveDict(catalog["Outlines"])
+	first, _ := doc.ResolveDict(outlines["First"])
+	last, _ := doc.ResolveDict(outlines["Last"])
+	if first["Title"] != String("Part One") || last["Title"] != String("Part Two") {
+		t.Errorf("outline = %v ... %v", first["Title"], last["Title"])
+	}
+	if first["Count"] != int64(-1) {
+		t.Errorf("closed item /Count = %v, want -1", first["Count"])
+	}
+	child, _ := doc.ResolveDict(first["First"])
+	if child["Title"] != TextString("Chapitre é") || !reflect.DeepEqual(child["Dest"], dest) {
+		t.Errorf("child item = %v", child)
+	}
+	if child["Parent"] != outlines["First"] {
+		t.Errorf("child /Parent = %v, want %v", child["Parent"], outlines["First"])
+	}
+
+	info, _ := doc.ResolveDict(doc.Trailer["Info"])
+	if info["Title"] != String("A Book") || info["Author"] != String("Someone") {
+		t.Errorf("Info = %v", info)
+	}
+	page, _ := doc.ResolveDict(pages[0])
+	if box, err := doc.rect(page["TrimBox"]); err != nil || box != trim {
+		t.Errorf("TrimBox = %v, %v, want %v", box, err, trim)
+	}
+
+	// A second update chains to the first
+	doc.SetInfo(Info{Subject: "Testing"})
+	again := doc.Bytes()
+	if chain2 := checkXref(t, again); len(chain2) != 3 || chain2[1] != chain[0] || chain2[2] != chain[1] {
+		t.Errorf("xref chain after a second update = %v, want a new section then %v", chain2, chain)
+	}
+	doc, err = Open(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ = doc.ResolveDict(doc.Trailer["Info"])
+	if info["Title"] != String("A Book") || info["Subject"] != String("Testing") {
+		t.Errorf("Info after a second update = %v", info)
+	}
+}
+
+func TestBytesWithoutUpdates(t *testing.T) {
+	data, _ := twoPages()
+	doc, err := Open(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := doc.Bytes(); !bytes.Equal(out, data) {
+		t.Error("Bytes() without updates changed the file")
+	}
+}
@@ -0,0 +1,228 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// inheritable are the page attributes a page may take from its ancestors
+// in the page tree
+var inheritable = []Name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// Extract writes a new PDF holding the given pages, in order, and every
+// object they use. The named destinations on those pages are kept, so
+// links between them still work; destinations on other pages become null.
+// The outline, Info dictionary and other document-wide entries are left
+// out for the caller to add.
+func (d *Document) Extract(pages []Ref) ([]byte, error) {
+	all, err := d.Pages()
+	if err != nil {
+		return nil, err
+	}
+	dropped := PageIndex(all)
+	for _, page := range pages {
+		delete(dropped, page.Num)
+	}
+
+	e := &extractor{doc: d, dropped: dropped, renumber: make(map[int]int)}
+	catalogNum, treeNum := e.alloc(), e.alloc()
+
+	// Number the pages first: they are copied here rather than queued
+	kids := make(Array, len(pages))
+	for i, page := range pages {
+		num := e.alloc()
+		e.renumber[page.Num] = num
+		kids[i] = Ref{Num: num}
+	}
+	for _, page := range pages {
+		node, err := d.ResolveDict(page)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			return nil, fmt.Errorf("pdf: page %d is missing", page.Num)
+		}
+		node = node.Copy()
+		for _, key := range inheritable {
+			if _, ok := node[key]; !ok {
+				if value, err := d.inherited(page, key); err != nil {
+					return nil, err
+				} else if value != nil {
+					node[key] = value
+				}
+			}
+		}
+		delete(node, "Parent")
+		copied, err := e.copy(node)
+		if err != nil {
+			return nil, err
+		}
+		copied.(Dict)["Parent"] = Ref{Num: treeNum}
+		e.objects[e.renumber[page.Num]-1] = copied
+	}
+
+	catalog := Dict{"Type": Name("Catalog"), "Pages": Ref{Num: treeNum}}
+	if dests, err := e.dests(); err != nil {
+		return nil, err
+	} else if len(dests) > 0 {
+		catalog["Names"] = Dict{"Dests": Dict{"Names": dests}}
+	}
+	e.objects[catalogNum-1] = catalog
+	e.objects[treeNum-1] = Dict{"Type": Name("Pages"), "Kids": kids, "Count": int64(len(kids))}
+
+	// Copy what the pages refer to, breadth first
+	for len(e.queue) > 0 {
+		ref := e.queue[0]
+		e.queue = e.queue[1:]
+		obj, err := d.Object(ref)
+		if err != nil {
+			return nil, err
+		}
+		if e.objects[e.renumber[ref.Num]-1], err = e.copy(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.write(catalogNum), nil
+}
+
+// inherited returns an attribute of page's ancestors, or nil
+func (d *Document) inherited(page Ref, key Name) (Object, error) {
+	node, err := d.ResolveDict(page)
+	for depth := 0; depth < 32 && err == nil && node != nil; depth++ {
+		if value, ok := node[key]; ok && depth > 0 {
+			return value, nil
+		}
+		node, err = d.ResolveDict(node["Parent"])
+	}
+	return nil, err
+}
+
+// extractor copies objects from a document into a new file, numbering them
+// from 1 in the order they are first referred to
+type extractor struct {
+	doc      *Document
+	dropped  map[int]int // Pages left out
+	renumber map[int]int // Old object number -> new
+	objects  []Object    // New object n is objects[n-1]
+	queue    []Ref       // Objects referred to but not copied yet
+}
+
+// alloc returns the number of a new object
+func (e *extractor) alloc() int {
+	e.objects = append(e.objects, nil)
+	return len(e.objects)
+}
+
+// ref returns the new number of an object, queuing it to be copied the
+// first time it is seen
+func (e *extractor) ref(ref Ref) int {
+	if num, ok := e.renumber[ref.Num]; ok {
+		return num
+	}
+	num := e.alloc()
+	e.renumber[ref.Num] = num
+	e.queue = append(e.queue, ref)
+	return num
+}
+
+// copy copies a direct object, renumbering its references. References to
+// dropped pages become null.
+func (e *extractor) copy(obj Object) (Object, error) {
+	switch v := obj.(type) {
+	case Ref:
+		if _, ok := e.dropped[v.Num]; ok {
+			return nil, nil
+		}
+		return Ref{Num: e.ref(v)}, nil
+	case Array:
+		out := make(Array, len(v))
+		for i, item := range v {
+			var err error
+			if out[i], err = e.copy(item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case Dict:
+		out := make(Dict, len(v))
+		for key, value := range v {
+			var err error
+			if out[key], err = e.copy(value); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case *Stream:
+		// The length is written out with the data
+		dict := v.Dict.Copy()
+		delete(dict, "Length")
+		copied, err := e.copy(dict)
+		if err != nil {
+			return nil, err
+		}
+		return &Stream{Dict: copied.(Dict), Data: v.Data}, nil
+	}
+	return obj, nil
+}
+
+// dests returns the named destinations on the kept pages as the Names
+// array of a name tree, sorted by name
+func (e *extractor) dests() (Array, error) {
+	dests, err := e.doc.NamedDests()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(dests))
+	for name, dest := range dests {
+		if len(dest) == 0 {
+			continue
+		}
+		if page, ok := dest[0].(Ref); ok {
+			if _, drop := e.dropped[page.Num]; !drop {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	out := make(Array, 0, 2*len(names))
+	for _, name := range names {
+		dest, err := e.copy(dests[name])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, String(name), dest)
+	}
+	return out, nil
+}
+
+// write serializes the copied objects as a complete PDF file
+func (e *extractor) write(root int) []byte {
+	var buf bytes.Buffer
+	header, _, _ := bytes.Cut(e.doc.data, []byte("\n"))
+	if !bytes.HasPrefix(header, []byte("%PDF-")) {
+		header = []byte("%PDF-1.4")
+	}
+	buf.Write(bytes.TrimRight(header, "\r"))
+	buf.WriteString("\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(e.objects))
+	for i, obj := range e.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		writeObject(&buf, obj)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(e.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	buf.WriteString("trailer\n")
+	writeObject(&buf, Dict{"Size": int64(len(e.objects) + 1), "Root": Ref{Num: root}})
+	fmt.Fprintf(&buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,151 @@
+package pdf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// threePages has a page tree passing its media box and resources down, a
+// link from the first page to the third and a named destination on each
+// of those two
+func threePages() []byte {
+	data, _ := buildPDF([]string{
+		"<</Type /Catalog /Pages 2 0 R /Names <</Dests 8 0 R>>>>",
+		"<</Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 /MediaBox [0 0 612 792] /Resources 6 0 R>>",
+		"<</Type /Page /Parent 2 0 R /Contents 7 0 R /Annots [<</Type /Annot /Subtype /Link /Dest [5 0 R /Fit]>>]>>",
+		"<</Type /Page /Parent 2 0 R /Contents 7 0 R>>",
+		"<</Type /Page /Parent 2 0 R /Rotate 90 /MediaBox [0 0 100 100]>>",
+		"<</Font <<>>>>",
+		"<</Length 8>>\nstream\n0 0 m S\n\nendstream",
+		"<</Names [(one) [3 0 R /Fit] (three) [5 0 R /XYZ 0 792 0]]>>",
+	}, "<</Size 9 /Root 1 0 R>>")
+	return data
+}
+
+func TestExtract(t *testing.T) {
+	doc, err := Open(threePages())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := doc.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		pages []Ref
+		boxes []Rect
+		dests []string
+		link  bool // The link on the first page still leads somewhere
+	}{
+		{"all", pages, []Rect{{0, 0, 612, 792}, {0, 0, 612, 792}, {0, 0, 100, 100}}, []string{"one", "three"}, true},
+		{"first and last", []Ref{pages[0], pages[2]}, []Rect{{0, 0, 612, 792}, {0, 0, 100, 100}}, []string{"one", "three"}, true},
+		{"first", pages[:1], []Rect{{0, 0, 612, 792}}, []string{"one"}, false},
+		{"middle", pages[1:2], []Rect{{0, 0, 612, 792}}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := doc.Extract(tt.pages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkXref(t, data)
+			part, err := Open(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := part.Pages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.pages) {
+				t.Fatalf("%d pages, want %d", len(got), len(tt.pages))
+			}
+
+			for i, page := range got {
+				if box, err := part.MediaBox(page); err != nil || box != tt.boxes[i] {
+					t.Errorf("page %d MediaBox = %v, %v, want %v", i+1, box, err, tt.boxes[i])
+				}
+				node, _ := part.ResolveDict(page)
+				if res, _ := part.ResolveDict(node["Resources"]); res == nil {
+					t.Errorf("page %d lost its inherited resources", i+1)
+				}
+				if parent, _ := part.ResolveDict(node["Parent"]); parent["Type"] != Name("Pages") {
+					t.Errorf("page %d /Parent = %v", i+1, node["Parent"])
+				}
+			}
+
+			// Content streams come along, shared ones once
+			first, _ := part.ResolveDict(got[0])
+			if obj, _ := part.Object(first["Contents"].(Ref)); obj == nil || string(obj.(*Stream).Data) != "0 0 m S\n" {
+				t.Errorf("first page contents = %#v", obj)
+			}
+			if n := bytes.Count(data, []byte("stream\n0 0 m S")); n != 1 {
+				t.Errorf("content stream written %d times", n)
+			}
+
+			dests, err := part.NamedDests()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			index := PageIndex(got)
+			for _, name := range []string{"one", "three"} {
+				if dest, ok := dests[name]; ok {
+					names = append(names, name)
+					if DestPage(dest, index) < 0 {
+						t.Errorf("destination %q points outside the part: %v", name, dest)
+					}
+				}
+			}
+			if !reflect.DeepEqual(names, tt.dests) {
+				t.Errorf("named destinations = %v, want %v", names, tt.dests)
+			}
+
+			if tt.pages[0] == pages[0] {
+				annots, _ := part.Resolve(first["Annots"])
+				link, _ := part.ResolveDict(annots.(Array)[0])
+				dest := link["Dest"].(Array)
+				if leads := DestPage(dest, index) >= 0; leads != tt.link {
+					t.Errorf("link destination %v leads into the part: %v, want %v", dest, leads, tt.link)
+				}
+				if !tt.link && dest[0] != nil {
+					t.Errorf("link to a dropped page = %v, want null", dest[0])
+				}
+			}
+		})
+	}
+}
+
+func TestExtractUpdated(t *testing.T) {
+	// Extracting reads the objects of pending and earlier updates
+	doc, err := Open(threePages())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, _ := doc.Pages()
+	trim := Rect{10, 10, 602, 782}
+	if err := doc.SetPageBoxes(pages[1], map[Name]Rect{"TrimBox": trim}); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := Open(doc.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := updated.Extract(pages[1:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := Open(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := part.Pages()
+	node, _ := part.ResolveDict(got[0])
+	if box, err := part.rect(node["TrimBox"]); err != nil || box != trim {
+		t.Errorf("TrimBox = %v, %v, want %v", box, err, trim)
+	}
+}
@@ -0,0 +1,107 @@
+package pdf
+
+import (
+	"fmt"
+	"time"
+)
+
+// Info holds the standard entries of the document information dictionary
+type Info struct {
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     string
+	Creator      string
+	Producer     string
+	CreationDate time.Time
+	ModDate      time.Time
+}
+
+// SetInfo merges info into the document information dictionary. Empty
+// fields leave any existing entry untouched.
+func (d *Document) SetInfo(info Info) error {
+	existing, err := d.ResolveDict(d.Trailer["Info"])
+	if err != nil {
+		return err
+	}
+
+	dict := Dict{}
+	if existing != nil {
+		dict = existing.Copy()
+	}
+
+	text := map[Name]string{
+		"Title":    info.Title,
+		"Author":   info.Author,
+		"Subject":  info.Subject,
+		"Keywords": info.Keywords,
+		"Creator":  info.Creator,
+		"Producer": info.Producer,
+	}
+	for key, value := range text {
+		if value != "" {
+			dict[key] = TextString(value)
+		}
+	}
+	if !info.CreationDate.IsZero() {
+		dict["CreationDate"] = FormatDate(info.CreationDate)
+	}
+	if !info.ModDate.IsZero() {
+		dict["ModDate"] = FormatDate(info.ModDate)
+	}
+
+	if ref, ok := d.Trailer["Info"].(Ref); ok {
+		d.Set(ref, dict)
+	} else {
+		d.Trailer["Info"] = d.Add(dict)
+	}
+	return nil
+}
+
+// SetXMP stores an XMP packet as the catalog's /Metadata stream
+func (d *Document) SetXMP(packet []byte) error {
+	catalog, catalogRef, err := d.Catalog()
+	if err != nil {
+		return err
+	}
+
+	stream := &Stream{
+		Dict: Dict{"Type": Name("Metadata"), "Subtype": Name("XML")},
+		Data: packet,
+	}
+
+	catalog = catalog.Copy()
+	if ref, ok := catalog["Metadata"].(Ref); ok {
+		d.Set(ref, stream)
+	} else {
+		catalog["Metadata"] = d.Add(stream)
+	}
+	d.Set(catalogRef, catalog)
+	return nil
+}
+
+// SetLanguage sets the natural language of the document (catalog /Lang)
+func (d *Document) SetLanguage(lang string) error {
+	catalog, catalogRef, err := d.Catalog()
+	if err != nil {
+		return err
+	}
+	catalog = catalog.Copy()
+	catalog["Lang"] = TextString(lang)
+	d.Set(catalogRef, catalog)
+	return nil
+}
+
+// FormatDate formats t as a PDF date string, e.g. D:20240131120000+01'00'
+func FormatDate(t time.Time) String {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	if offset == 0 {
+		return String(t.Format("D:20060102150405") + "Z")
+	}
+	return String(fmt.Sprintf("%s%c%02d'%02d'", t.Format("D:20060102150405"), sign, offset/3600, offset%3600/60))
+}
@@ -0,0 +1,169 @@
+// Package pdf implements just enough of the PDF file format to post-process
+// the documents produced by headless Chrome: reading objects through the
+// cross-reference table and appending changes as an incremental update.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Object is any PDF object: nil (null), bool, int64, float64, Name, String,
+// Array, Dict, Ref or *Stream
+type Object interface{}
+
+// Name is a PDF name object such as /Type
+type Name string
+
+// String is a PDF string object holding raw bytes
+type String string
+
+// Array is a PDF array object
+type Array []Object
+
+// Dict is a PDF dictionary object
+type Dict map[Name]Object
+
+// Ref is an indirect object reference such as 12 0 R
+type Ref struct {
+	Num int
+	Gen int
+}
+
+// Stream is a PDF stream object; Data holds the (possibly encoded) bytes
+type Stream struct {
+	Dict Dict
+	Data []byte
+}
+
+// TextString encodes s as a PDF text string, using UTF-16BE when it is not plain ASCII
+func TextString(s string) String {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return String(s)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\xfe\xff")
+	for _, u := range utf16.Encode([]rune(s)) {
+		buf.WriteByte(byte(u >> 8))
+		buf.WriteByte(byte(u))
+	}
+	return String(buf.String())
+}
+
+// Copy returns a shallow copy of the dictionary
+func (d Dict) Copy() Dict {
+	c := make(Dict, len(d))
+	for k, v := range d {
+		c[k] = v
+	}
+	return c
+}
+
+// writeObject serializes obj in PDF syntax
+func writeObject(buf *bytes.Buffer, obj Object) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case Name:
+		writeName(buf, v)
+	case String:
+		writeString(buf, v)
+	case Ref:
+		fmt.Fprintf(buf, "%d %d R", v.Num, v.Gen)
+	case Array:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+
+		buf.WriteString("<<")
+		for _, k := range keys {
+			writeName(buf, Name(k))
+			buf.WriteByte(' ')
+			writeObject(buf, v[Name(k)])
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(">>")
+	case *Stream:
+		dict := v.Dict.Copy()
+		dict["Length"] = int64(len(v.Data))
+		writeObject(buf, dict)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.Data)
+		buf.WriteString("\nendstream")
+	default:
+		panic(fmt.Sprintf("pdf: cannot serialize %T", obj))
+	}
+}
+
+func writeName(buf *bytes.Buffer, n Name) {
+	buf.WriteByte('/')
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c <= ' ' || c >= 0x7f || c == '#' || isDelimiter(c) {
+			fmt.Fprintf(buf, "#%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+}
+
+func writeString(buf *bytes.Buffer, s String) {
+	printable := true
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] >= 0x7f {
+			printable = false
+			break
+		}
+	}
+
+	if !printable {
+		fmt.Fprintf(buf, "<%X>", []byte(s))
+		return
+	}
+
+	buf.WriteByte('(')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', ')', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte(')')
+}
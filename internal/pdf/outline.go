@@ -0,0 +1,72 @@
+package pdf
+
+// OutlineItem is a bookmark in the document outline
+type OutlineItem struct {
+	Title    string
+	Dest     Array // Explicit destination, e.g. [page /XYZ x y 0]
+	Children []OutlineItem
+}
+
+// SetOutline replaces the document outline with the given bookmark tree and
+// asks viewers to show it when the document is opened
+func (d *Document) SetOutline(items []OutlineItem) error {
+	catalog, catalogRef, err := d.Catalog()
+	if err != nil {
+		return err
+	}
+
+	catalog = catalog.Copy()
+	if len(items) == 0 {
+		delete(catalog, "Outlines")
+		d.Set(catalogRef, catalog)
+		return nil
+	}
+
+	rootRef := d.Add(nil)
+	first, last := d.addOutlineItems(items, rootRef)
+	d.Set(rootRef, Dict{
+		"Type":  Name("Outlines"),
+		"First": first,
+		"Last":  last,
+		"Count": int64(len(items)),
+	})
+
+	catalog["Outlines"] = rootRef
+	catalog["PageMode"] = Name("UseOutlines")
+	d.Set(catalogRef, catalog)
+	return nil
+}
+
+// addOutlineItems writes one level of the outline and returns its first and
+// last item. Nested levels start closed so the top level stays readable.
+func (d *Document) addOutlineItems(items []OutlineItem, parent Ref) (Ref, Ref) {
+	refs := make([]Ref, len(items))
+	for i := range items {
+		refs[i] = d.Add(nil)
+	}
+
+	for i, item := range items {
+		dict := Dict{
+			"Title":  TextString(item.Title),
+			"Parent": parent,
+		}
+		if item.Dest != nil {
+			dict["Dest"] = item.Dest
+		}
+		if i > 0 {
+			dict["Prev"] = refs[i-1]
+		}
+		if i < len(items)-1 {
+			dict["Next"] = refs[i+1]
+		}
+		if len(item.Children) > 0 {
+			first, last := d.addOutlineItems(item.Children, refs[i])
+			dict["First"] = first
+			dict["Last"] = last
+			dict["Count"] = -int64(len(item.Children))
+		}
+		d.Set(refs[i], dict)
+	}
+
+	return refs[0], refs[len(refs)-1]
+}
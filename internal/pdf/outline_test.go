@@ -0,0 +1,106 @@
+package pdf
+
+import (
+	"reflect"
+	"testing"
+)
+
+// readOutline walks the items under an outline node through /First and
+// /Next, checking each level's /Last, /Prev and /Parent links and /Count
+func readOutline(t *testing.T, doc *Document, nodeRef Object, node Dict) []OutlineItem {
+	t.Helper()
+	var items []OutlineItem
+	var prev Object
+	for ref := node["First"]; ref != nil; {
+		item, err := doc.ResolveDict(ref)
+		if err != nil || item == nil {
+			t.Fatalf("outline item %v: %v", ref, err)
+		}
+		if item["Parent"] != nodeRef {
+			t.Errorf("%s: /Parent = %v, want %v", item["Title"], item["Parent"], nodeRef)
+		}
+		if item["Prev"] != prev {
+			t.Errorf("%s: /Prev = %v, want %v", item["Title"], item["Prev"], prev)
+		}
+
+		title, _ := item["Title"].(String)
+		dest, _ := item["Dest"].(Array)
+		children := readOutline(t, doc, ref, item)
+		if want := -int64(len(children)); len(children) > 0 && item["Count"] != want {
+			t.Errorf("%s: /Count = %v, want %d (closed)", title, item["Count"], want)
+		} else if len(children) == 0 && (item["Count"] != nil || item["First"] != nil || item["Last"] != nil) {
+			t.Errorf("%s: leaf has /Count %v, /First %v, /Last %v", title, item["Count"], item["First"], item["Last"])
+		}
+		items = append(items, OutlineItem{Title: string(title), Dest: dest, Children: children})
+
+		if len(items) > 50 {
+			t.Fatal("outline /Next chain doesn't end")
+		}
+		prev, ref = ref, item["Next"]
+	}
+	if node["Last"] != prev {
+		t.Errorf("/Last = %v, want %v", node["Last"], prev)
+	}
+	return items
+}
+
+func TestSetOutline(t *testing.T) {
+	data, _ := twoPages()
+	doc, err := Open(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, _ := doc.Pages()
+
+	outline := []OutlineItem{
+		{Title: "Cover", Dest: Array{pages[0], Name("Fit")}},
+		{Title: "Part One", Dest: Array{pages[0], Name("XYZ"), nil, int64(792), nil}, Children: []OutlineItem{
+			{Title: "Chapter 1", Dest: Array{pages[0], Name("XYZ"), nil, int64(400), nil}},
+			{Title: "Chapter 2", Dest: Array{pages[1], Name("Fit")}, Children: []OutlineItem{
+				{Title: "Section 2.1", Dest: Array{pages[1], Name("XYZ"), nil, int64(300), nil}},
+				{Title: "Section 2.2", Dest: Array{pages[1], Name("XYZ"), nil, int64(100), nil}},
+			}},
+			{Title: "Heading without a page"},
+		}},
+		{Title: "Part Two", Dest: Array{pages[1], Name("Fit")}},
+	}
+	if err := doc.SetOutline(outline); err != nil {
+		t.Fatal(err)
+	}
+	doc, err = Open(doc.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, _, err := doc.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := doc.ResolveDict(catalog["Outlines"])
+	if err != nil || root == nil {
+		t.Fatalf("catalog /Outlines = %v, %v", catalog["Outlines"], err)
+	}
+	if root["Type"] != Name("Outlines") || root["Count"] != int64(len(outline)) {
+		t.Errorf("outline root /Type %v, /Count %v, want /Outlines, %d", root["Type"], root["Count"], len(outline))
+	}
+	if got := readOutline(t, doc, catalog["Outlines"], root); !reflect.DeepEqual(got, outline) {
+		t.Errorf("outline read back =\n%+v\nwant\n%+v", got, outline)
+	}
+
+	// A new outline replaces the old one, and none removes it
+	if err := doc.SetOutline(outline[:1]); err != nil {
+		t.Fatal(err)
+	}
+	catalog, _, _ = doc.Catalog()
+	root, _ = doc.ResolveDict(catalog["Outlines"])
+	if got := readOutline(t, doc, catalog["Outlines"], root); !reflect.DeepEqual(got, outline[:1]) {
+		t.Errorf("replaced outline = %+v, want %+v", got, outline[:1])
+	}
+	if err := doc.SetOutline(nil); err != nil {
+		t.Fatal(err)
+	}
+	catalog, _, _ = doc.Catalog()
+	if _, ok := catalog["Outlines"]; ok {
+		t.Errorf("catalog keeps /Outlines %v after removing the outline", catalog["Outlines"])
+	}
+}
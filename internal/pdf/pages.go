@@ -0,0 +1,175 @@
+package pdf
+
+import (
+	"fmt"
+)
+
+// Pages returns the page objects in document order
+func (d *Document) Pages() ([]Ref, error) {
+	catalog, _, err := d.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	root, ok := catalog["Pages"].(Ref)
+	if !ok {
+		return nil, fmt.Errorf("pdf: catalog has no page tree")
+	}
+
+	var pages []Ref
+	seen := make(map[int]bool)
+	var walk func(ref Ref) error
+	walk = func(ref Ref) error {
+		if seen[ref.Num] {
+			return fmt.Errorf("pdf: page tree cycle at object %d", ref.Num)
+		}
+		seen[ref.Num] = true
+
+		node, err := d.ResolveDict(ref)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return fmt.Errorf("pdf: page tree node %d is missing", ref.Num)
+		}
+
+		if node["Type"] == Name("Page") {
+			pages = append(pages, ref)
+			return nil
+		}
+
+		kids, err := d.Resolve(node["Kids"])
+		if err != nil {
+			return err
+		}
+		arr, _ := kids.(Array)
+		for _, kid := range arr {
+			if kidRef, ok := kid.(Ref); ok {
+				if err := walk(kidRef); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// PageIndex maps page object numbers to their zero-based position
+func PageIndex(pages []Ref) map[int]int {
+	index := make(map[int]int, len(pages))
+	for i, p := range pages {
+		index[p.Num] = i
+	}
+	return index
+}
+
+// NamedDests returns the named destinations defined in the catalog's /Dests
+// dictionary and in the /Names /Dests name tree. Each destination is an
+// explicit destination array whose first element is the page reference.
+func (d *Document) NamedDests() (map[string]Array, error) {
+	catalog, _, err := d.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	dests := make(map[string]Array)
+
+	// PDF 1.1 style: /Dests << /name [page /XYZ x y z] >>
+	if old, err := d.ResolveDict(catalog["Dests"]); err != nil {
+		return nil, err
+	} else if old != nil {
+		for name, value := range old {
+			if dest := d.destArray(value); dest != nil {
+				dests[string(name)] = dest
+			}
+		}
+	}
+
+	// PDF 1.2 style: /Names << /Dests name tree >>
+	names, err := d.ResolveDict(catalog["Names"])
+	if err != nil {
+		return nil, err
+	}
+	if names != nil {
+		if err := d.walkNameTree(names["Dests"], dests, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return dests, nil
+}
+
+func (d *Document) walkNameTree(obj Object, dests map[string]Array, depth int) error {
+	if depth > 32 {
+		return fmt.Errorf("pdf: name tree too deep")
+	}
+	node, err := d.ResolveDict(obj)
+	if err != nil || node == nil {
+		return err
+	}
+
+	if kids, err := d.Resolve(node["Kids"]); err != nil {
+		return err
+	} else if arr, ok := kids.(Array); ok {
+		for _, kid := range arr {
+			if err := d.walkNameTree(kid, dests, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	pairs, err := d.Resolve(node["Names"])
+	if err != nil {
+		return err
+	}
+	arr, _ := pairs.(Array)
+	for i := 0; i+1 < len(arr); i += 2 {
+		key, ok := arr[i].(String)
+		if !ok {
+			continue
+		}
+		if dest := d.destArray(arr[i+1]); dest != nil {
+			dests[string(key)] = dest
+		}
+	}
+	return nil
+}
+
+// destArray accepts either an explicit destination array or a dictionary
+// with a /D entry, as allowed for named destinations
+func (d *Document) destArray(obj Object) Array {
+	obj, err := d.Resolve(obj)
+	if err != nil {
+		return nil
+	}
+	switch v := obj.(type) {
+	case Array:
+		return v
+	case Dict:
+		if inner, err := d.Resolve(v["D"]); err == nil {
+			if arr, ok := inner.(Array); ok {
+				return arr
+			}
+		}
+	}
+	return nil
+}
+
+// DestPage returns the zero-based page number a destination points at, or -1
+func DestPage(dest Array, index map[int]int) int {
+	if len(dest) == 0 {
+		return -1
+	}
+	ref, ok := dest[0].(Ref)
+	if !ok {
+		return -1
+	}
+	if page, ok := index[ref.Num]; ok {
+		return page
+	}
+	return -1
+}
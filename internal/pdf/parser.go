@@ -0,0 +1,252 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// parser reads PDF objects from a byte slice
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func isWhitespace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isWhitespace(c) {
+			p.pos++
+			continue
+		}
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+}
+
+// keyword reads a run of regular characters (numbers, operators, keywords)
+func (p *parser) keyword() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && !isWhitespace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("pdf: offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// object parses the next direct object. References (n g R) are recognised
+// by looking ahead after an integer.
+func (p *parser) object() (Object, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of data")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		return p.name(), nil
+	case c == '(':
+		return p.literalString()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		return p.dict()
+	case c == '<':
+		return p.hexString()
+	case c == '[':
+		p.pos++
+		var arr Array
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return nil, p.errorf("unterminated array")
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return arr, nil
+			}
+			item, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+		}
+	}
+
+	word := p.keyword()
+	switch word {
+	case "":
+		return nil, p.errorf("unexpected character %q", p.data[p.pos])
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	n, err := strconv.ParseInt(word, 10, 64)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(word, 64)
+		if ferr != nil {
+			return nil, p.errorf("unexpected token %q", word)
+		}
+		return f, nil
+	}
+
+	// Look ahead for "gen R"
+	save := p.pos
+	if gen, err := strconv.Atoi(p.keyword()); err == nil && p.keyword() == "R" {
+		return Ref{Num: int(n), Gen: gen}, nil
+	}
+	p.pos = save
+	return n, nil
+}
+
+func (p *parser) name() Name {
+	p.pos++ // skip '/'
+	var buf bytes.Buffer
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isWhitespace(c) || isDelimiter(c) {
+			break
+		}
+		if c == '#' && p.pos+2 < len(p.data) {
+			if v, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				buf.WriteByte(byte(v))
+				p.pos += 3
+				continue
+			}
+		}
+		buf.WriteByte(c)
+		p.pos++
+	}
+	return Name(buf.String())
+}
+
+func (p *parser) literalString() (String, error) {
+	p.pos++ // skip '('
+	var buf bytes.Buffer
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(buf.String()), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				break
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case 'b':
+				buf.WriteByte('\b')
+			case 'f':
+				buf.WriteByte('\f')
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+			case '\n':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := int(e - '0')
+				for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+					v = v*8 + int(p.data[p.pos]-'0')
+					p.pos++
+				}
+				buf.WriteByte(byte(v))
+			default:
+				buf.WriteByte(e)
+			}
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) hexString() (String, error) {
+	p.pos++ // skip '<'
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end == -1 {
+		return "", p.errorf("unterminated hex string")
+	}
+
+	var digits []byte
+	for _, c := range p.data[p.pos : p.pos+end] {
+		if !isWhitespace(c) {
+			digits = append(digits, c)
+		}
+	}
+	p.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return "", p.errorf("invalid hex string")
+		}
+		out[i] = byte(v)
+	}
+	return String(out), nil
+}
+
+func (p *parser) dict() (Dict, error) {
+	p.pos += 2 // skip '<<'
+	d := Dict{}
+	for {
+		p.skipSpace()
+		if p.pos+1 < len(p.data) && p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return d, nil
+		}
+		if p.pos >= len(p.data) || p.data[p.pos] != '/' {
+			return nil, p.errorf("expected dictionary key")
+		}
+		key := p.name()
+		value, err := p.object()
+		if err != nil {
+			return nil, err
+		}
+		d[key] = value
+	}
+}
@@ -0,0 +1,132 @@
+package pdf
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Object
+	}{
+		{"null", "null", nil},
+		{"true", "true", true},
+		{"false", "false", false},
+		{"integer", "42", int64(42)},
+		{"negative", "-7", int64(-7)},
+		{"real", "3.25", 3.25},
+		{"leading dot", ".5", 0.5},
+		{"reference", "12 0 R", Ref{Num: 12}},
+		{"reference with generation", "3 2 R", Ref{Num: 3, Gen: 2}},
+		{"integers not a reference", "[1 2 3]", Array{int64(1), int64(2), int64(3)}},
+		{"name", "/Type", Name("Type")},
+		{"name with escape", "/A#20B", Name("A B")},
+		{"name ends at delimiter", "/Kids[", Name("Kids")},
+		{"literal string", "(Hello)", String("Hello")},
+		{"nested parentheses", "(a (b) c)", String("a (b) c")},
+		{"escapes", `(\(\)\\\n\t)`, String("()\\\n\t")},
+		{"octal escapes", `(\101\102\7)`, String("AB\x07")},
+		{"line continuation", "(a\\\nb)", String("ab")},
+		{"hex string", "<48656C6C6F>", String("Hello")},
+		{"hex string with spaces", "<48 65 6c>", String("Hel")},
+		{"hex string odd length", "<414>", String("A@")},
+		{"empty array", "[]", Array(nil)},
+		{"mixed array", "[/Name (s) 1.5 2 0 R]", Array{Name("Name"), String("s"), 1.5, Ref{Num: 2}}},
+		{"nested array", "[[1] [2]]", Array{Array{int64(1)}, Array{int64(2)}}},
+		{"dictionary", "<</Type /Page /Count 3>>", Dict{"Type": Name("Page"), "Count": int64(3)}},
+		{"nested dictionary", "<</A <</B 1 0 R>>>>", Dict{"A": Dict{"B": Ref{Num: 1}}}},
+		{"comment", "% a comment\n/Name", Name("Name")},
+		{"leading whitespace", "\r\n\t 7", int64(7)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &parser{data: []byte(tt.in)}
+			got, err := p.object()
+			if err != nil {
+				t.Fatalf("object(%q): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("object(%q) = %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseObjectErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ""},
+		{"unterminated array", "[1 2"},
+		{"unterminated string", "(abc"},
+		{"unterminated hex string", "<4142"},
+		{"invalid hex string", "<zz>"},
+		{"dictionary key not a name", "<<1 2>>"},
+		{"unterminated dictionary", "<</A 1"},
+		{"unknown keyword", "foo"},
+		{"stray delimiter", ")"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &parser{data: []byte(tt.in)}
+			if got, err := p.object(); err == nil {
+				t.Errorf("object(%q) = %#v, want an error", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestWriteObjectRoundTrip(t *testing.T) {
+	objects := []Object{
+		nil,
+		true,
+		int64(-12),
+		2.5,
+		Name("Name with spaces/and#hash"),
+		String("plain (with parentheses) and \\ backslash"),
+		String("binary \x00\xff"),
+		TextString("Ünïcödé"),
+		Ref{Num: 7, Gen: 1},
+		Array{int64(1), Name("A"), Array{String("x")}},
+		Dict{"Type": Name("Catalog"), "Pages": Ref{Num: 2}, "Nested": Dict{"K": Array{}}},
+	}
+	for _, obj := range objects {
+		var buf bytes.Buffer
+		writeObject(&buf, obj)
+		p := &parser{data: buf.Bytes()}
+		got, err := p.object()
+		if err != nil {
+			t.Fatalf("parsing %q: %v", buf.String(), err)
+		}
+		want := obj
+		if arr, ok := obj.(Array); ok && len(arr) == 0 {
+			want = Array(nil)
+		}
+		if dict, ok := obj.(Dict); ok {
+			// Empty arrays parse back as nil
+			want = dict.Copy()
+			want.(Dict)["Nested"] = Dict{"K": Array(nil)}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q parsed back as %#v, want %#v", buf.String(), got, want)
+		}
+	}
+}
+
+func TestTextString(t *testing.T) {
+	if got := TextString("Plain"); got != "Plain" {
+		t.Errorf("TextString(ASCII) = %q", got)
+	}
+	got := TextString("é")
+	if want := String("\xfe\xff\x00\xe9"); got != want {
+		t.Errorf("TextString(é) = %q, want %q", got, want)
+	}
+	if !strings.HasPrefix(string(TextString("😀")), "\xfe\xff\xd8\x3d") {
+		t.Errorf("TextString doesn't encode surrogate pairs")
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+)
+
+// Job states
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// job is a background conversion. Its exported fields are its status as
+// reported by GET /jobs/{id}.
+type job struct {
+	mu sync.Mutex
+
+	ID       string     `json:"id"`
+	Status   string     `json:"status"`
+	Title    string     `json:"title,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Size     int        `json:"size,omitempty"` // PDF size in bytes
+	Pages    int        `json:"pages,omitempty"`
+	Warnings []string   `json:"warnings,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	PDF      string     `json:"pdf,omitempty"` // URL of the PDF once done
+
+	pdfPath string // The PDF, in a temporary file
+}
+
+// status returns a copy of the job's status
+func (j *job) status() *job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return &job{
+		ID: j.ID, Status: j.Status, Title: j.Title, Error: j.Error, Size: j.Size,
+		Pages: j.Pages, Warnings: j.Warnings, Created: j.Created, Finished: j.Finished,
+		PDF: j.PDF, pdfPath: j.pdfPath,
+	}
+}
+
+// finish records the outcome of the job
+func (j *job) finish(pdfPath, title string, size int, result converter.Result, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.Finished = &now
+	j.Title = title
+	j.Warnings = result.Warnings
+	if err != nil {
+		j.Status, j.Error = jobFailed, err.Error()
+		return
+	}
+	j.Status, j.Size, j.Pages, j.pdfPath = jobDone, size, result.Pages, pdfPath
+	j.PDF = "/jobs/" + j.ID + "/pdf"
+}
+
+// remove deletes the job's PDF
+func (j *job) remove() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.pdfPath != "" {
+		os.Remove(j.pdfPath)
+		j.pdfPath = ""
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	req, err := s.parseRequest(w, r)
+	if err != nil {
+		writeError(w, requestStatus(err), err)
+		return
+	}
+
+	j := &job{ID: newJobID(), Status: jobQueued, Created: time.Now()}
+	s.mu.Lock()
+	s.jobs[j.ID] = j
+	s.mu.Unlock()
+	go s.runJob(j, req)
+
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, j.status())
+}
+
+// runJob converts the book of a job once a slot is free
+func (s *Server) runJob(j *job, req *request) {
+	defer os.Remove(req.epubPath)
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-s.ctx.Done():
+		j.finish("", "", 0, converter.Result{}, s.ctx.Err())
+		return
+	}
+	j.mu.Lock()
+	j.Status = jobRunning
+	j.mu.Unlock()
+	data, title, result, err := s.convert(s.ctx, req)
+	<-s.slots
+
+	if err != nil {
+		log.Printf("job %s: %v", j.ID, err)
+		j.finish("", title, 0, result, err)
+		return
+	}
+
+	tmp, err := os.CreateTemp("", "epub2pdf-*.pdf")
+	if err == nil {
+		_, err = tmp.Write(data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to store PDF: %w", err)
+		log.Printf("job %s: %v", j.ID, err)
+		j.finish("", title, 0, result, err)
+		return
+	}
+	log.Printf("job %s: %q, %d pages in %s", j.ID, title, result.Pages, result.Timings.Total.Round(time.Millisecond))
+	j.finish(tmp.Name(), title, len(data), result, nil)
+}
+
+// job returns the job of a request's {id}, or writes a 404
+func (s *Server) job(w http.ResponseWriter, r *http.Request) *job {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such job: %s", r.PathValue("id")))
+		return nil
+	}
+	return j
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if j := s.job(w, r); j != nil {
+		writeJSON(w, http.StatusOK, j.status())
+	}
+}
+
+func (s *Server) handleJobPDF(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	status := j.status()
+	if status.Status != jobDone {
+		writeJSON(w, http.StatusConflict, status)
+		return
+	}
+
+	file, err := os.Open(status.pdfPath)
+	if err != nil {
+		writeError(w, http.StatusGone, fmt.Errorf("the PDF has expired"))
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", attachment(status.Title))
+	http.ServeContent(w, r, "", *status.Finished, file)
+}
+
+// expireJobs removes finished jobs older than the result TTL until the
+// server is closed
+func (s *Server) expireJobs() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, j := range s.jobs {
+				status := j.status()
+				if status.Finished != nil && now.Sub(*status.Finished) > s.cfg.ResultTTL {
+					j.remove()
+					delete(s.jobs, id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
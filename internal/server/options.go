@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+	"github.com/vib795/epub2pdf/internal/epub"
+)
+
+// optionNames are the options a request may set. They are named after the
+// command-line flags; css takes stylesheet text rather than a file.
+var optionNames = []string{
+	"page-size", "margin", "margin-top", "margin-bottom", "margin-inside", "margin-outside",
+	"landscape", "no-background", "scale", "bookmarks", "title-page", "fixed-layout",
+	"headers", "header", "footer", "toc-page", "prefer-css-page-size",
+	"theme", "font-family", "font-size", "line-height", "css", "no-book-css",
+	"print", "bleed", "crop-marks", "pages", "chapters", "exclude",
+}
+
+// selection holds the chapter filters of a request
+type selection struct {
+	include, exclude epub.ChapterFilter
+}
+
+// decodeJSONOptions decodes a JSON object of options into values. Arrays
+// give repeated values, as for css.
+func decodeJSONOptions(data []byte, values url.Values) error {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid options JSON: %w", err)
+	}
+	str := func(v any) (string, error) {
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+	for key, v := range raw {
+		items, ok := v.([]any)
+		if !ok {
+			items = []any{v}
+		}
+		values.Del(key)
+		for _, item := range items {
+			s, err := str(item)
+			if err != nil {
+				return fmt.Errorf("option %s: %w", key, err)
+			}
+			values.Add(key, s)
+		}
+	}
+	return nil
+}
+
+// parseOptions builds the conversion options and chapter selection of a
+// request. Options that are not set keep the command line's defaults.
+func parseOptions(values url.Values) (converter.Options, selection, error) {
+	known := make(map[string]bool, len(optionNames))
+	for _, name := range optionNames {
+		known[name] = true
+	}
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return converter.Options{}, selection{}, fmt.Errorf("unknown options: %s", strings.Join(unknown, ", "))
+	}
+
+	p := optionParser{values: values}
+	opts := converter.DefaultOptions()
+	p.string("page-size", &opts.PageSize)
+	p.margins(&opts.Margins)
+	p.bool("landscape", &opts.Landscape)
+	noBackground := false
+	p.bool("no-background", &noBackground)
+	opts.PrintBG = !noBackground
+	p.float("scale", &opts.Scale)
+	p.bool("bookmarks", &opts.Bookmarks)
+	p.string("title-page", &opts.TitlePage)
+	p.string("fixed-layout", &opts.FixedLayout)
+	p.headers(&opts.Header, &opts.Footer)
+	p.bool("toc-page", &opts.TOCPage)
+	p.bool("prefer-css-page-size", &opts.PreferCSSPageSize)
+	p.string("theme", &opts.Theme)
+	p.string("font-family", &opts.FontFamily)
+	p.string("font-size", &opts.FontSize)
+	p.float("line-height", &opts.LineHeight)
+	opts.UserCSS = values["css"]
+	p.bool("no-book-css", &opts.NoBookCSS)
+	p.bool("print", &opts.Print)
+	p.length("bleed", &opts.Bleed)
+	p.bool("crop-marks", &opts.CropMarks)
+	p.string("pages", &opts.PageRanges)
+	var sel selection
+	p.filter("chapters", &sel.include)
+	p.filter("exclude", &sel.exclude)
+	if p.err != nil {
+		return converter.Options{}, selection{}, p.err
+	}
+
+	if err := opts.Validate(); err != nil {
+		return converter.Options{}, selection{}, err
+	}
+	return opts, sel, nil
+}
+
+// optionParser parses option values, keeping the first error
+type optionParser struct {
+	values url.Values
+	err    error
+}
+
+func (p *optionParser) fail(key string, err error) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%s: %w", key, err)
+	}
+}
+
+func (p *optionParser) string(key string, dst *string) {
+	if p.values.Has(key) {
+		*dst = p.values.Get(key)
+	}
+}
+
+// bool parses a boolean; an empty value, as sent by a bare form field or
+// checkbox, is true
+func (p *optionParser) bool(key string, dst *bool) {
+	if !p.values.Has(key) {
+		return
+	}
+	switch v := strings.ToLower(p.values.Get(key)); v {
+	case "", "on":
+		*dst = true
+	default:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			p.fail(key, fmt.Errorf("invalid boolean: %s", v))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (p *optionParser) float(key string, dst *float64) {
+	if !p.values.Has(key) {
+		return
+	}
+	f, err := strconv.ParseFloat(p.values.Get(key), 64)
+	if err != nil {
+		p.fail(key, fmt.Errorf("invalid number: %s", p.values.Get(key)))
+		return
+	}
+	*dst = f
+}
+
+func (p *optionParser) length(key string, dst *float64) {
+	if v := p.values.Get(key); v != "" {
+		l, err := converter.ParseLength(v)
+		if err != nil {
+			p.fail(key, err)
+			return
+		}
+		*dst = l
+	}
+}
+
+// margins applies margin, then the per-side margins
+func (p *optionParser) margins(dst *converter.Margins) {
+	if v := p.values.Get("margin"); v != "" {
+		margins, err := converter.ParseMargins(v)
+		if err != nil {
+			p.fail("margin", err)
+			return
+		}
+		*dst = margins
+	}
+	p.length("margin-top", &dst.Top)
+	p.length("margin-bottom", &dst.Bottom)
+	p.length("margin-inside", &dst.Inside)
+	p.length("margin-outside", &dst.Outside)
+}
+
+// headers resolves the headers preset, overridden by explicit templates
+func (p *optionParser) headers(header, footer *string) {
+	name := "none"
+	p.string("headers", &name)
+	preset, ok := epub.HeaderFooterPresets[name]
+	if !ok {
+		p.fail("headers", fmt.Errorf("invalid preset: %s (valid: %s)", name, strings.Join(epub.PresetNames(), ", ")))
+		return
+	}
+	*header, *footer = preset.Header, preset.Footer
+	p.string("header", header)
+	p.string("footer", footer)
+}
+
+func (p *optionParser) filter(key string, dst *epub.ChapterFilter) {
+	if v := p.values.Get(key); v != "" {
+		f, err := epub.ParseChapterFilter(v)
+		if err != nil {
+			p.fail(key, err)
+			return
+		}
+		*dst = f
+	}
+}
@@ -0,0 +1,265 @@
+// Package server implements the HTTP conversion service of "epub2pdf serve".
+// Conversions run in tabs of one long-lived headless Chrome, a limited
+// number at a time.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+	"github.com/vib795/epub2pdf/internal/epub"
+)
+
+// Config holds the server settings
+type Config struct {
+	Concurrency int           // Conversions running at once
+	JobTimeout  time.Duration // Limit on one conversion
+	MaxUpload   int64         // Largest accepted EPUB in bytes
+	ResultTTL   time.Duration // How long finished jobs are kept
+}
+
+// Server converts uploaded EPUB files. It serves:
+//
+//	POST /convert          convert and respond with the PDF
+//	POST /jobs             start a conversion in the background
+//	GET  /jobs/{id}        the job's status
+//	GET  /jobs/{id}/pdf    the finished job's PDF
+//	GET  /healthz          a liveness check
+type Server struct {
+	browser *converter.Browser
+	cfg     Config
+	mux     *http.ServeMux
+	slots   chan struct{} // One per running conversion
+
+	mu   sync.Mutex
+	jobs map[string]*job
+
+	ctx    context.Context // Done when the server is closed
+	cancel context.CancelFunc
+}
+
+// New returns a server converting books in tabs of browser
+func New(browser *converter.Browser, cfg Config) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		browser: browser,
+		cfg:     cfg,
+		mux:     http.NewServeMux(),
+		slots:   make(chan struct{}, max(1, cfg.Concurrency)),
+		jobs:    make(map[string]*job),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	s.mux.HandleFunc("POST /convert", s.handleConvert)
+	s.mux.HandleFunc("POST /jobs", s.handleCreateJob)
+	s.mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	s.mux.HandleFunc("GET /jobs/{id}/pdf", s.handleJobPDF)
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	go s.expireJobs()
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close cancels running jobs and removes the files of all jobs
+func (s *Server) Close() {
+	s.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, j := range s.jobs {
+		j.remove()
+		delete(s.jobs, id)
+	}
+}
+
+// request is a parsed conversion request
+type request struct {
+	epubPath string // The upload, in a temporary file
+	opts     converter.Options
+	sel      selection
+}
+
+// parseRequest reads the EPUB and options of a request. It accepts either
+// multipart/form-data with the EPUB in the "epub" field and options as
+// form fields or as a JSON object in the "options" field, or the EPUB as
+// the request body with options in the query string.
+func (s *Server) parseRequest(w http.ResponseWriter, r *http.Request) (*request, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxUpload)
+
+	values := url.Values{}
+	var upload io.Reader
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, fmt.Errorf("invalid form: %w", err)
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		for key, v := range r.MultipartForm.Value {
+			if key != "options" {
+				values[key] = v
+			}
+		}
+		if v := r.MultipartForm.Value["options"]; len(v) > 0 {
+			if err := decodeJSONOptions([]byte(v[0]), values); err != nil {
+				return nil, err
+			}
+		}
+
+		file, _, err := r.FormFile("epub")
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, fmt.Errorf("missing EPUB file in the \"epub\" field")
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid upload: %w", err)
+		}
+		defer file.Close()
+		upload = file
+	} else {
+		values = r.URL.Query()
+		upload = r.Body
+	}
+
+	opts, sel, err := parseOptions(values)
+	if err != nil {
+		return nil, err
+	}
+	opts.Timeout = s.cfg.JobTimeout
+
+	tmp, err := os.CreateTemp("", "epub2pdf-*.epub")
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	defer tmp.Close()
+	n, err := io.Copy(tmp, upload)
+	if err == nil && n == 0 {
+		err = fmt.Errorf("empty upload")
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	return &request{epubPath: tmp.Name(), opts: opts, sel: sel}, nil
+}
+
+// convert renders the request's book and returns the PDF and the book's
+// title. The caller must hold a slot.
+func (s *Server) convert(ctx context.Context, req *request) ([]byte, string, converter.Result, error) {
+	book, err := epub.Parse(req.epubPath)
+	if err != nil {
+		return nil, "", converter.Result{}, &requestError{fmt.Errorf("failed to parse EPUB: %w", err)}
+	}
+	defer book.Close()
+	if !req.sel.include.Empty() || !req.sel.exclude.Empty() {
+		if err := book.SelectChapters(req.sel.include, req.sel.exclude); err != nil {
+			return nil, book.Title, converter.Result{}, &requestError{err}
+		}
+	}
+
+	// Uploads and their stylesheets are untrusted: no scripts, and no
+	// requests beyond the book itself
+	data, result, err := converter.Render(ctx, s.browser.Sandboxed(), book, req.opts)
+	for _, warning := range result.Warnings {
+		log.Printf("warning: %q: %s", book.Title, warning)
+	}
+	return data, book.Title, result, err
+}
+
+// requestError is a conversion failure caused by the request rather than
+// the server
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+func (e *requestError) Unwrap() error { return e.err }
+
+func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	req, err := s.parseRequest(w, r)
+	if err != nil {
+		writeError(w, requestStatus(err), err)
+		return
+	}
+	defer os.Remove(req.epubPath)
+
+	// Wait for a slot unless the client gives up first
+	select {
+	case s.slots <- struct{}{}:
+	case <-r.Context().Done():
+		return
+	}
+	data, title, result, err := s.convert(r.Context(), req)
+	<-s.slots
+
+	if err != nil {
+		log.Printf("convert: %v", err)
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			writeError(w, http.StatusUnprocessableEntity, err)
+		} else {
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	log.Printf("convert: %q, %d pages in %s", title, result.Pages, result.Timings.Total.Round(time.Millisecond))
+
+	// The body is the PDF, so warnings travel in headers; background jobs
+	// list them in their status
+	for _, warning := range result.Warnings {
+		w.Header().Add("X-Warning", strings.Join(strings.Fields(warning), " "))
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", attachment(title))
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.Write(data)
+}
+
+// requestStatus is the status code for a request that could not be parsed
+func requestStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// attachment returns a Content-Disposition header naming the PDF after
+// the book
+func attachment(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = "book"
+	}
+	return mime.FormatMediaType("attachment", map[string]string{"filename": name + ".pdf"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
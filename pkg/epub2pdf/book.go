@@ -0,0 +1,95 @@
+// Package epub2pdf converts EPUB books to PDF.
+//
+// Parse or Open a book, then convert it with a Converter, which lays the
+// book out as one HTML document and prints it with a Renderer:
+//
+//	chrome, err := epub2pdf.NewChrome(epub2pdf.ChromeOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	defer chrome.Close()
+//
+//	book, err := epub2pdf.Open("book.epub")
+//	if err != nil {
+//		return err
+//	}
+//	defer book.Close()
+//
+//	opts := epub2pdf.DefaultOptions()
+//	opts.PageSize = "Letter"
+//	c := epub2pdf.NewConverter(chrome, opts)
+//	result, err := c.Convert(ctx, book, w)
+//
+// A Chrome renderer and a Converter may be shared by goroutines; each
+// conversion prints in a tab of its own.
+package epub2pdf
+
+import (
+	"io"
+
+	"github.com/vib795/epub2pdf/internal/epub"
+)
+
+// Book is a parsed EPUB book
+type Book struct {
+	book *epub.Book
+}
+
+// Open parses the EPUB file at path. The file stays open until Close.
+func Open(path string) (*Book, error) {
+	book, err := epub.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Book{book: book}, nil
+}
+
+// Parse parses an EPUB of the given size read from r. Resources are read
+// from r while converting, so it must stay readable until the book is no
+// longer used.
+func Parse(r io.ReaderAt, size int64) (*Book, error) {
+	book, err := epub.ParseReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return &Book{book: book}, nil
+}
+
+// Close releases the book's file, if it was opened with Open
+func (b *Book) Close() error {
+	return b.book.Close()
+}
+
+// Title returns the book's title
+func (b *Book) Title() string {
+	return b.book.Title
+}
+
+// Author returns the book's authors, separated by commas
+func (b *Book) Author() string {
+	return b.book.Author
+}
+
+// Language returns the book's main language, e.g. "en", or ""
+func (b *Book) Language() string {
+	if len(b.book.Metadata.Languages) == 0 {
+		return ""
+	}
+	return b.book.Metadata.Languages[0]
+}
+
+// Chapters returns the titles of the book's chapters in reading order.
+// Options.Chapters numbers them from 1 in this order.
+func (b *Book) Chapters() []string {
+	titles := make([]string, len(b.book.Chapters))
+	for i, chapter := range b.book.Chapters {
+		titles[i] = chapter.Title
+	}
+	return titles
+}
+
+// FixedLayout reports whether the book is pre-paginated, like comics and
+// picture books
+func (b *Book) FixedLayout() bool {
+	return b.book.FixedLayout
+}
@@ -0,0 +1,227 @@
+package epub2pdf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+	"github.com/vib795/epub2pdf/internal/epub"
+)
+
+// Options control a conversion. Start from DefaultOptions: the zero value
+// is not valid.
+type Options struct {
+	PageSize        string  // A4, Letter, 6x9, "148x210mm", ...
+	Margins         Margins // In inches
+	Landscape       bool
+	PrintBackground bool    // Print background colors and images
+	Scale           float64 // 0.1 - 2.0
+	Bookmarks       bool    // Add a PDF outline from the book's TOC
+	TitlePage       string  // cover, generated or none
+	FixedLayout     string  // fit or viewport: how pre-paginated pages are sized
+	Header          string  // Header template ("left|center|right"), empty for none
+	Footer          string  // Footer template
+	TOCPage         bool    // Print a table of contents with page numbers
+
+	// PreferCSSPageSize lets the book's own @page rules set the page size
+	// and margins, falling back to PageSize and Margins
+	PreferCSSPageSize bool
+
+	// Typography: a theme (serif, sans, large-print, dyslexia-friendly,
+	// e-ink) and overrides of its font family, size and line height
+	Theme      string
+	FontFamily string  // CSS font-family list
+	FontSize   string  // CSS length such as 12pt or 1.2em
+	LineHeight float64 // Multiple of the font size, 0 for the theme's
+
+	UserCSS   []string // Stylesheets applied after the book's, as CSS text
+	NoBookCSS bool     // Drop the book's own stylesheets
+
+	// Chapters to convert and to leave out: numbers and ranges as listed
+	// by Book.Chapters ("3-7,12"), titles or /regular expressions/
+	Chapters string
+	Exclude  string
+
+	PageRanges string // Pages to keep, e.g. "1-5, 8, 11-13"; empty for all
+
+	// Print-ready output: mirrored margins, chapters on right-hand pages,
+	// Bleed inches of bleed around the trimmed page and optional crop marks
+	Print     bool
+	Bleed     float64
+	CropMarks bool
+
+	// Timeout limits the conversion on top of the context's deadline, 0
+	// for no limit
+	Timeout time.Duration
+
+	// Progress, if set, is called with a short description of each step
+	Progress func(step string)
+}
+
+// Margins are page margins in inches. Inside is the binding side: the
+// left margin of right-hand pages.
+type Margins struct {
+	Top, Bottom, Inside, Outside float64
+}
+
+// UniformMargins returns the same margin on all sides
+func UniformMargins(inches float64) Margins {
+	return Margins{inches, inches, inches, inches}
+}
+
+// DefaultOptions returns the command line's defaults: A4 pages with half
+// inch margins, backgrounds, bookmarks, the cover as the first page and a
+// two minute timeout
+func DefaultOptions() Options {
+	opts := converter.DefaultOptions()
+	return Options{
+		PageSize:        opts.PageSize,
+		Margins:         Margins(opts.Margins),
+		PrintBackground: opts.PrintBG,
+		Scale:           opts.Scale,
+		Bookmarks:       opts.Bookmarks,
+		TitlePage:       opts.TitlePage,
+		FixedLayout:     opts.FixedLayout,
+		Timeout:         opts.Timeout,
+	}
+}
+
+// Validate checks the options
+func (o Options) Validate() error {
+	if _, err := o.chapterFilters(); err != nil {
+		return err
+	}
+	return o.internal().Validate()
+}
+
+func (o Options) internal() converter.Options {
+	return converter.Options{
+		PageSize:          o.PageSize,
+		Margins:           converter.Margins(o.Margins),
+		Landscape:         o.Landscape,
+		PrintBG:           o.PrintBackground,
+		Scale:             o.Scale,
+		Bookmarks:         o.Bookmarks,
+		TitlePage:         o.TitlePage,
+		FixedLayout:       o.FixedLayout,
+		Header:            o.Header,
+		Footer:            o.Footer,
+		TOCPage:           o.TOCPage,
+		PreferCSSPageSize: o.PreferCSSPageSize,
+		Theme:             o.Theme,
+		FontFamily:        o.FontFamily,
+		FontSize:          o.FontSize,
+		LineHeight:        o.LineHeight,
+		UserCSS:           o.UserCSS,
+		NoBookCSS:         o.NoBookCSS,
+		PageRanges:        o.PageRanges,
+		Print:             o.Print,
+		Bleed:             o.Bleed,
+		CropMarks:         o.CropMarks,
+		Timeout:           o.Timeout,
+		Progress:          o.Progress,
+	}
+}
+
+// chapterFilters parses Chapters and Exclude
+func (o Options) chapterFilters() ([2]epub.ChapterFilter, error) {
+	var filters [2]epub.ChapterFilter
+	var err error
+	if filters[0], err = epub.ParseChapterFilter(o.Chapters); err != nil {
+		return filters, fmt.Errorf("chapters: %w", err)
+	}
+	if filters[1], err = epub.ParseChapterFilter(o.Exclude); err != nil {
+		return filters, fmt.Errorf("exclude: %w", err)
+	}
+	return filters, nil
+}
+
+// Result describes a finished conversion
+type Result struct {
+	Pages    int      // Pages in the PDF, 0 if it couldn't be read back
+	Warnings []string // Problems that didn't stop the conversion
+	Timings  Timings
+}
+
+// Timings break down the time a conversion took
+type Timings struct {
+	Layout      time.Duration // Building the merged HTML document
+	Render      time.Duration // Printing it to PDF, twice with a TOC page
+	PostProcess time.Duration // Bookmarks, metadata and print marks
+	Write       time.Duration // Writing the PDF out
+	Total       time.Duration
+}
+
+// Converter converts books to PDF with a Renderer
+type Converter struct {
+	renderer Renderer
+	opts     Options
+}
+
+// NewConverter returns a converter printing with renderer
+func NewConverter(renderer Renderer, opts Options) *Converter {
+	return &Converter{renderer: renderer, opts: opts}
+}
+
+// Convert converts the book and writes the PDF to w. Nothing is written
+// if the conversion fails. Books may be converted concurrently.
+func (c *Converter) Convert(ctx context.Context, book *Book, w io.Writer) (*Result, error) {
+	start := time.Now()
+	b, opts, err := c.prepare(book)
+	if err != nil {
+		return nil, err
+	}
+	data, result, err := converter.Render(ctx, c.internalRenderer(), b, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	writeStart := time.Now()
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+
+	return &Result{
+		Pages:    result.Pages,
+		Warnings: result.Warnings,
+		Timings: Timings{
+			Layout:      result.Timings.Layout,
+			Render:      result.Timings.Render,
+			PostProcess: result.Timings.PostProcess,
+			Write:       time.Since(writeStart),
+			Total:       time.Since(start),
+		},
+	}, nil
+}
+
+// prepare validates the options and selects the chapters to convert, in a
+// copy of the book
+func (c *Converter) prepare(book *Book) (*epub.Book, converter.Options, error) {
+	filters, err := c.opts.chapterFilters()
+	if err != nil {
+		return nil, converter.Options{}, err
+	}
+	opts := c.opts.internal()
+	if err := opts.Validate(); err != nil {
+		return nil, opts, err
+	}
+
+	b := book.book
+	if !filters[0].Empty() || !filters[1].Empty() {
+		b = b.Part(0, len(b.Chapters))
+		if err := b.SelectChapters(filters[0], filters[1]); err != nil {
+			return nil, opts, err
+		}
+	}
+	return b, opts, nil
+}
+
+// internalRenderer returns the renderer for the internal converter
+func (c *Converter) internalRenderer() converter.Renderer {
+	if chrome, ok := c.renderer.(*Chrome); ok {
+		return chrome.browser
+	}
+	return rendererAdapter{c.renderer}
+}
@@ -0,0 +1,95 @@
+package epub2pdf
+
+import (
+	"context"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+)
+
+// Renderer prints an HTML document to PDF. Chrome is the built-in
+// implementation; other engines can be plugged into a Converter by
+// implementing this interface. Headers and footers are CSS page-margin
+// boxes (@top-center etc.), which the engine must draw to print them.
+type Renderer interface {
+	// PrintToPDF loads the document at url, an HTTP URL on the loopback
+	// interface also serving the book's images, fonts and stylesheets,
+	// and prints it. It must stop when ctx is done.
+	PrintToPDF(ctx context.Context, url string, params PrintParams) ([]byte, error)
+}
+
+// PrintParams describe how to print a document. Lengths are in inches.
+type PrintParams struct {
+	PaperWidth   float64
+	PaperHeight  float64
+	MarginTop    float64
+	MarginBottom float64
+	MarginLeft   float64 // Right-hand pages' inside margin; @page :left mirrors it
+	MarginRight  float64
+
+	PrintBackground   bool
+	Scale             float64
+	PreferCSSPageSize bool   // Let the document's @page rules size the paper
+	PageRanges        string // Pages to keep, e.g. "1-5, 8"; empty for all
+}
+
+// Chrome is a Renderer printing with headless Chrome
+type Chrome struct {
+	browser *converter.Browser
+}
+
+// ChromeOptions choose and launch the Chrome executable. The zero value
+// finds Chrome on its own.
+type ChromeOptions struct {
+	// Path is the executable, a path or a name looked up in PATH. When
+	// empty, $EPUB2PDF_CHROME is used, then the usual install locations
+	// are searched, headless shells first.
+	Path string
+
+	// Flags are extra command-line flags such as "no-sandbox", needed to
+	// run as root, or "--window-size=800,600". "name=false" removes a
+	// default flag.
+	Flags []string
+
+	// Remote is the DevTools URL of an already running Chrome to print
+	// with instead, e.g. "ws://localhost:9222". The book is sent over the
+	// DevTools connection, so Chrome needn't reach this machine.
+	Remote string
+}
+
+// BrowserNotFoundError is returned by NewChrome when no Chrome executable
+// was found. It lists the locations that were searched.
+type BrowserNotFoundError = converter.BrowserNotFoundError
+
+// NewChrome starts headless Chrome, or connects to a remote one. Close it
+// when done.
+func NewChrome(opts ChromeOptions) (*Chrome, error) {
+	browser, err := converter.NewBrowser(context.Background(), converter.ChromeOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &Chrome{browser: browser}, nil
+}
+
+// Path returns the Chrome executable, or the remote Chrome's URL
+func (c *Chrome) Path() string {
+	return c.browser.Path()
+}
+
+// PrintToPDF implements Renderer, printing in a new tab
+func (c *Chrome) PrintToPDF(ctx context.Context, url string, params PrintParams) ([]byte, error) {
+	return c.browser.PrintToPDF(ctx, url, converter.PrintParams(params))
+}
+
+// Close shuts Chrome down, or disconnects from a remote Chrome
+func (c *Chrome) Close() {
+	c.browser.Close()
+}
+
+// rendererAdapter lets the internal converter use a public Renderer
+type rendererAdapter struct {
+	renderer Renderer
+}
+
+func (a rendererAdapter) PrintToPDF(ctx context.Context, url string, params converter.PrintParams) ([]byte, error) {
+	return a.renderer.PrintToPDF(ctx, url, PrintParams(params))
+}
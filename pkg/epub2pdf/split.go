@@ -0,0 +1,43 @@
+package epub2pdf
+
+import (
+	"context"
+
+	"github.com/vib795/epub2pdf/internal/converter"
+)
+
+// Split describes how to split a book into several PDFs
+type Split = converter.Split
+
+// Split modes
+const (
+	SplitChapter  = converter.SplitChapter  // One PDF per chapter
+	SplitTOCLevel = converter.SplitTOCLevel // One PDF per TOC entry at depth N
+	SplitPages    = converter.SplitPages    // One PDF per N pages
+)
+
+// ParseSplit parses a split mode as given on the command line: chapter,
+// toc-level-N or pages:N
+func ParseSplit(s string) (Split, error) {
+	return converter.ParseSplit(s)
+}
+
+// Part is one PDF of a split book. Name is a numbered file name such as
+// "03-chapter-one.pdf", so the parts sort in order.
+type Part = converter.Part
+
+// ConvertSplit converts the book into a numbered series of PDFs, passing
+// each part to emit, in order, as soon as it is done. Chapters and TOC
+// sections are rendered from their own chapters, and only the first part
+// keeps the title page; runs of pages are cut from one render of the whole
+// book, so page ranges can't be combined with them, and a TOC page needs
+// them. Each part carries the book's metadata with its section in the
+// title. It returns the warnings of the conversion, naming their parts; an
+// error from emit stops the conversion and is returned.
+func (c *Converter) ConvertSplit(ctx context.Context, book *Book, split Split, emit func(Part) error) ([]string, error) {
+	b, opts, err := c.prepare(book)
+	if err != nil {
+		return nil, err
+	}
+	return converter.RenderSplit(ctx, c.internalRenderer(), b, split, opts, emit)
+}